@@ -6,6 +6,8 @@ package main
 
 import (
 	"context"
+	"os"
+	"strings"
 
 	"github.com/drone/drone-artifactory/plugin"
 
@@ -16,29 +18,37 @@ import (
 func main() {
 	logrus.SetFormatter(new(formatter))
 
+	// Load the settings from an env file, to run outside of drone
+	if path := os.Getenv("PLUGIN_ENV_FILE"); path != "" {
+		if err := plugin.LoadEnvFile(path); err != nil {
+			logrus.Fatalln(err)
+		}
+	}
+
 	var args plugin.Args
 	if err := envconfig.Process("", &args); err != nil {
 		logrus.Fatalln(err)
 	}
 
-	switch args.Level {
-	case "debug":
-		logrus.SetFormatter(textFormatter)
-		logrus.SetLevel(logrus.DebugLevel)
-	case "trace":
+	level := plugin.LogLevel(args.Level)
+	if level >= logrus.DebugLevel {
 		logrus.SetFormatter(textFormatter)
-		logrus.SetLevel(logrus.TraceLevel)
 	}
+	logrus.SetLevel(level)
 
 	if err := plugin.Exec(context.Background(), args); err != nil {
-		logrus.Fatalln(err)	
+		logrus.Fatalln(err)
 	}
 }
 
 // default formatter that writes logs without including timestamp or level information.
-type formatter struct {}
+type formatter struct{}
+
 func (*formatter) Format(entry *logrus.Entry) ([]byte, error) {
-	return []byte(entry.Message), nil
+	if strings.HasSuffix(entry.Message, "\n") {
+		return []byte(entry.Message), nil
+	}
+	return []byte(entry.Message + "\n"), nil
 }
 
 // text formatter that writes logs with level information
@@ -0,0 +1,241 @@
+// Copyright 2020 the Drone Authors. All rights reserved.
+// Use of this source code is governed by the Blue Oak Model License
+// that can be found in the LICENSE file.
+
+package plugin
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/sirupsen/logrus"
+)
+
+// publishBuildCommand returns the jfrog rt bp arguments used to
+// publish the collected build info to artifactory.
+func publishBuildCommand(args Args) ([]string, error) {
+	cmdArgs, err := baseCommand(args, "rt", "bp")
+	if err != nil {
+		return nil, err
+	}
+	name, number, err := requireBuild(args)
+	if err != nil {
+		return nil, err
+	}
+	// The environment filters apply when the collected
+	// variables are published.
+	if args.EnvInclude != "" {
+		cmdArgs = append(cmdArgs, fmt.Sprintf("--env-include=%s", args.EnvInclude))
+	}
+	if args.EnvExclude != "" {
+		cmdArgs = append(cmdArgs, fmt.Sprintf("--env-exclude=%s", args.EnvExclude))
+	}
+	if url := buildURL(args); url != "" {
+		cmdArgs = append(cmdArgs, fmt.Sprintf("--build-url=%s", url))
+	}
+	cmdArgs = append(cmdArgs, projectArgs(args)...)
+	return append(cmdArgs, name, number), nil
+}
+
+// publishBuildInfo returns true if the build info is published
+// after the upload. A dry run uploads nothing, so nothing is
+// published.
+func publishBuildInfo(args Args) bool {
+	return isUpload(args) &&
+		parseBoolOrDefault(false, args.PublishBuildInfo) &&
+		!uploadDryRun(args)
+}
+
+// isPublishBuild returns true if the command publishes the build
+// info.
+func isPublishBuild(cmdArgs []string) bool {
+	return len(cmdArgs) > 1 && cmdArgs[0] == "rt" && cmdArgs[1] == "bp"
+}
+
+// collectEnvCommand returns the jfrog rt bce arguments used to
+// collect the environment variables into the local build info.
+// The build info is stored locally, so no server flags are needed.
+func collectEnvCommand(args Args) ([]string, error) {
+	name, number, err := requireBuild(args)
+	if err != nil {
+		return nil, err
+	}
+	return []string{"rt", "bce", name, number}, nil
+}
+
+// collectGitCommand returns the jfrog rt bag arguments used to add
+// the git revision and url to the local build info. The repository
+// path defaults to the working directory.
+func collectGitCommand(args Args) ([]string, error) {
+	name, number, err := requireBuild(args)
+	if err != nil {
+		return nil, err
+	}
+	path, err := gitPath(args)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := os.Stat(filepath.Join(resolvePath(args, path), ".git")); err != nil {
+		return nil, fmt.Errorf("no git repository found at %q", path)
+	}
+	return []string{"rt", "bag", name, number, path}, nil
+}
+
+// gitPath returns the path of the git repository, which defaults to
+// the directory the jfrog command runs in.
+func gitPath(args Args) (string, error) {
+	if args.GitPath != "" {
+		return args.GitPath, nil
+	}
+	if dir := commandDir(args); dir != "" {
+		return dir, nil
+	}
+	path, err := os.Getwd()
+	if err != nil {
+		return "", fmt.Errorf("error getting working directory: %s", err)
+	}
+	return path, nil
+}
+
+// collectDepsCommand returns the jfrog rt bad arguments used to add
+// the local files matching the source pattern to the local build
+// info as dependencies.
+func collectDepsCommand(args Args) ([]string, error) {
+	name, number, err := requireBuild(args)
+	if err != nil {
+		return nil, err
+	}
+	if args.Source == "" {
+		return nil, fmt.Errorf("source needs to be set")
+	}
+	return []string{"rt", "bad", name, number, args.Source}, nil
+}
+
+// buildAppendCommand returns the jfrog rt ba arguments used to add
+// a published build to the local build info, so that the builds of
+// several jobs are published as one.
+func buildAppendCommand(args Args) ([]string, error) {
+	name, number, err := requireBuild(args)
+	if err != nil {
+		return nil, err
+	}
+	if args.AppendBuildName == "" {
+		return nil, fmt.Errorf("append build name needs to be set")
+	}
+	if args.AppendBuildNumber == "" {
+		return nil, fmt.Errorf("append build number needs to be set")
+	}
+	return []string{"rt", "ba", name, number, args.AppendBuildName, args.AppendBuildNumber}, nil
+}
+
+// promoteCommand returns the jfrog rt bpr arguments used to promote
+// the build to the target repository. The promotion completes before
+// the cli returns, so sync does not apply.
+func promoteCommand(args Args) ([]string, error) {
+	cmdArgs, err := baseCommand(args, "rt", "bpr")
+	if err != nil {
+		return nil, err
+	}
+	name, number, err := requireBuild(args)
+	if err != nil {
+		return nil, err
+	}
+	if args.TargetRepo == "" {
+		return nil, fmt.Errorf("target repo needs to be set")
+	}
+	if args.PromoteStatus != "" {
+		cmdArgs = append(cmdArgs, fmt.Sprintf("--status=%s", args.PromoteStatus))
+	}
+	if args.PromoteComment != "" {
+		cmdArgs = append(cmdArgs, fmt.Sprintf("--comment=%s", args.PromoteComment))
+	}
+	if parseBoolOrDefault(false, args.PromoteCopy) {
+		cmdArgs = append(cmdArgs, "--copy=true")
+	}
+	cmdArgs = append(cmdArgs, projectArgs(args)...)
+	return append(cmdArgs, name, number, args.TargetRepo), nil
+}
+
+// discardBuildsCommand returns the jfrog rt bdi arguments used to
+// discard old runs of the build.
+func discardBuildsCommand(args Args) ([]string, error) {
+	cmdArgs, err := baseCommand(args, "rt", "bdi")
+	if err != nil {
+		return nil, err
+	}
+	if args.BuildName == "" {
+		return nil, fmt.Errorf("build name needs to be set")
+	}
+	if args.MaxBuilds <= 0 && args.MaxDays <= 0 {
+		return nil, fmt.Errorf("either max builds or max days needs to be set")
+	}
+	if args.MaxBuilds > 0 {
+		cmdArgs = append(cmdArgs, fmt.Sprintf("--max-builds=%d", args.MaxBuilds))
+	}
+	if args.MaxDays > 0 {
+		cmdArgs = append(cmdArgs, fmt.Sprintf("--max-days=%d", args.MaxDays))
+	}
+	if args.ExcludeBuilds != "" {
+		cmdArgs = append(cmdArgs, fmt.Sprintf("--exclude-builds=%s", args.ExcludeBuilds))
+	}
+	return append(cmdArgs, args.BuildName), nil
+}
+
+// buildArgs returns the build name, number and module flags used
+// to associate an operation with the build info, or nil if no
+// build name is set.
+func buildArgs(args Args) []string {
+	name, number := buildInfo(args)
+	if name == "" || number == "" {
+		if args.Module != "" {
+			logrus.Warnln("Module is set without a build name and number, ignoring it.")
+		}
+		return nil
+	}
+	cmdArgs := []string{
+		fmt.Sprintf("--build-name=%s", name),
+		fmt.Sprintf("--build-number=%s", number),
+	}
+	if args.Module != "" {
+		cmdArgs = append(cmdArgs, fmt.Sprintf("--module=%s", args.Module))
+	}
+	return cmdArgs
+}
+
+// requireBuild returns the build name and number, or an
+// error if either is missing.
+func requireBuild(args Args) (string, string, error) {
+	name, number := buildInfo(args)
+	if name == "" {
+		return "", "", fmt.Errorf("build name needs to be set")
+	}
+	if number == "" {
+		return "", "", fmt.Errorf("build number needs to be set")
+	}
+	return name, number, nil
+}
+
+// buildInfo returns the build name and number. The build name
+// defaults to the drone repository name and the build number to
+// the drone build number.
+func buildInfo(args Args) (name, number string) {
+	name, number = args.BuildName, args.BuildNumber
+	if name == "" {
+		name = args.Repo.Name
+	}
+	if number == "" && args.Build.Number != 0 {
+		number = strconv.Itoa(args.Build.Number)
+	}
+	return name, number
+}
+
+// buildURL returns the url of the ci build linked from the build
+// info. It defaults to the drone build link.
+func buildURL(args Args) string {
+	if args.BuildURL != "" {
+		return args.BuildURL
+	}
+	return args.Build.Link
+}
@@ -0,0 +1,566 @@
+// Copyright 2020 the Drone Authors. All rights reserved.
+// Use of this source code is governed by the Blue Oak Model License
+// that can be found in the LICENSE file.
+
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/kelseyhightower/envconfig"
+	"github.com/sirupsen/logrus"
+)
+
+func TestPublishBuildCommand(t *testing.T) {
+	args := Args{
+		Command:     "publish-build",
+		URL:         "https://artifactory.example.com",
+		APIKey:      "key",
+		BuildName:   "app",
+		BuildNumber: "42",
+	}
+	cmdArgs, err := buildCommand(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `rt bp --url=https://artifactory.example.com --apikey=key app 42`
+	if got := strings.Join(cmdArgs, " "); got != want {
+		t.Errorf("Want command\n%s\ngot\n%s", want, got)
+	}
+}
+
+func TestPublishBuildCommandDefaultNumber(t *testing.T) {
+	args := Args{
+		Command:   "publish-build",
+		URL:       "https://artifactory.example.com",
+		APIKey:    "key",
+		BuildName: "app",
+	}
+	args.Build.Number = 7
+	cmdArgs, err := buildCommand(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `rt bp --url=https://artifactory.example.com --apikey=key app 7`
+	if got := strings.Join(cmdArgs, " "); got != want {
+		t.Errorf("Want command\n%s\ngot\n%s", want, got)
+	}
+}
+
+func TestPublishBuildCommandErrors(t *testing.T) {
+	args := Args{
+		Command: "publish-build",
+		URL:     "https://artifactory.example.com",
+		APIKey:  "key",
+	}
+	if _, err := buildCommand(args); err == nil {
+		t.Error("Expect error when build name is missing")
+	}
+	args.BuildName = "app"
+	if _, err := buildCommand(args); err == nil {
+		t.Error("Expect error when build number is missing")
+	}
+}
+
+func TestUploadBuildArgs(t *testing.T) {
+	args := Args{
+		URL:         "https://artifactory.example.com",
+		APIKey:      "key",
+		Source:      "dist/app.zip",
+		Target:      "repo/app/",
+		BuildName:   "app",
+		BuildNumber: "42",
+	}
+	cmdArgs, err := buildCommand(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `rt u --url=https://artifactory.example.com --apikey=key --build-name=app --build-number=42 dist/app.zip repo/app/`
+	if got := strings.Join(cmdArgs, " "); got != want {
+		t.Errorf("Want command\n%s\ngot\n%s", want, got)
+	}
+}
+
+func TestCollectEnvCommand(t *testing.T) {
+	args := Args{
+		Command:     "collect-env",
+		URL:         "https://artifactory.example.com",
+		BuildName:   "app",
+		BuildNumber: "42",
+	}
+	cmdArgs, err := buildCommand(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `rt bce app 42`
+	if got := strings.Join(cmdArgs, " "); got != want {
+		t.Errorf("Want command\n%s\ngot\n%s", want, got)
+	}
+
+	args.BuildName = ""
+	if _, err := buildCommand(args); err == nil {
+		t.Error("Expect error when build name is missing")
+	}
+}
+
+func TestPublishBuildCommandEnvFilters(t *testing.T) {
+	args := Args{
+		Command:     "publish-build",
+		URL:         "https://artifactory.example.com",
+		APIKey:      "key",
+		BuildName:   "app",
+		BuildNumber: "42",
+		EnvInclude:  "DRONE_*;CI",
+		EnvExclude:  "*password*;*secret*;*key*",
+	}
+	cmdArgs, err := buildCommand(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `rt bp --url=https://artifactory.example.com --apikey=key --env-include=DRONE_*;CI --env-exclude=*password*;*secret*;*key* app 42`
+	if got := strings.Join(cmdArgs, " "); got != want {
+		t.Errorf("Want command\n%s\ngot\n%s", want, got)
+	}
+}
+
+func TestPromoteCommand(t *testing.T) {
+	tests := []struct {
+		name string
+		args Args
+		want string
+	}{
+		{
+			name: "required only",
+			args: Args{
+				Command:     "promote",
+				URL:         "https://artifactory.example.com",
+				APIKey:      "key",
+				BuildName:   "app",
+				BuildNumber: "42",
+				TargetRepo:  "libs-release",
+			},
+			want: `rt bpr --url=https://artifactory.example.com --apikey=key app 42 libs-release`,
+		},
+		{
+			name: "optional flags",
+			args: Args{
+				Command:        "promote",
+				URL:            "https://artifactory.example.com",
+				APIKey:         "key",
+				BuildName:      "app",
+				BuildNumber:    "42",
+				TargetRepo:     "libs-release",
+				PromoteStatus:  "released",
+				PromoteComment: "promoted by drone",
+				PromoteCopy:    "true",
+			},
+			want: `rt bpr --url=https://artifactory.example.com --apikey=key --status=released --comment=promoted by drone --copy=true app 42 libs-release`,
+		},
+	}
+	for _, test := range tests {
+		cmdArgs, err := buildCommand(test.args)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.name, err)
+			continue
+		}
+		if got := strings.Join(cmdArgs, " "); got != test.want {
+			t.Errorf("%s: want command\n%s\ngot\n%s", test.name, test.want, got)
+		}
+	}
+}
+
+func TestPromoteCommandErrors(t *testing.T) {
+	args := Args{
+		Command:     "promote",
+		URL:         "https://artifactory.example.com",
+		APIKey:      "key",
+		BuildName:   "app",
+		BuildNumber: "42",
+	}
+	if _, err := buildCommand(args); err == nil {
+		t.Error("Expect error when target repo is missing")
+	}
+}
+
+func TestCollectGitCommand(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	args := Args{
+		Command:     "collect-git",
+		URL:         "https://artifactory.example.com",
+		BuildName:   "app",
+		BuildNumber: "42",
+		GitPath:     dir,
+	}
+	cmdArgs, err := buildCommand(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `rt bag app 42 ` + dir
+	if got := strings.Join(cmdArgs, " "); got != want {
+		t.Errorf("Want command\n%s\ngot\n%s", want, got)
+	}
+}
+
+func TestCollectGitCommandMissingRepo(t *testing.T) {
+	args := Args{
+		Command:     "collect-git",
+		URL:         "https://artifactory.example.com",
+		BuildName:   "app",
+		BuildNumber: "42",
+		GitPath:     t.TempDir(),
+	}
+	_, err := buildCommand(args)
+	if err == nil {
+		t.Fatal("Expect error when the path is not a git repository")
+	}
+	if !strings.Contains(err.Error(), "no git repository found") {
+		t.Errorf("Expect descriptive error, got %q", err)
+	}
+}
+
+func TestDiscardBuildsCommand(t *testing.T) {
+	tests := []struct {
+		name string
+		args Args
+		want string
+	}{
+		{
+			name: "max builds",
+			args: Args{MaxBuilds: 10},
+			want: `rt bdi --url=https://artifactory.example.com --apikey=key --max-builds=10 app`,
+		},
+		{
+			name: "max days",
+			args: Args{MaxDays: 30},
+			want: `rt bdi --url=https://artifactory.example.com --apikey=key --max-days=30 app`,
+		},
+		{
+			name: "all options",
+			args: Args{MaxBuilds: 10, MaxDays: 30, ExcludeBuilds: "1,2,3"},
+			want: `rt bdi --url=https://artifactory.example.com --apikey=key --max-builds=10 --max-days=30 --exclude-builds=1,2,3 app`,
+		},
+	}
+	for _, test := range tests {
+		args := test.args
+		args.Command = "discard-builds"
+		args.URL = "https://artifactory.example.com"
+		args.APIKey = "key"
+		args.BuildName = "app"
+		cmdArgs, err := buildCommand(args)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.name, err)
+			continue
+		}
+		if got := strings.Join(cmdArgs, " "); got != test.want {
+			t.Errorf("%s: want command\n%s\ngot\n%s", test.name, test.want, got)
+		}
+	}
+}
+
+func TestDiscardBuildsCommandErrors(t *testing.T) {
+	args := Args{
+		Command:   "discard-builds",
+		URL:       "https://artifactory.example.com",
+		APIKey:    "key",
+		BuildName: "app",
+	}
+	if _, err := buildCommand(args); err == nil {
+		t.Error("Expect error when neither max builds nor max days is set")
+	}
+	args.BuildName = ""
+	args.MaxDays = 30
+	if _, err := buildCommand(args); err == nil {
+		t.Error("Expect error when build name is missing")
+	}
+}
+
+func TestBuildArgsModule(t *testing.T) {
+	for _, command := range []string{"upload", "download"} {
+		args := Args{
+			Command:     command,
+			URL:         "https://artifactory.example.com",
+			APIKey:      "key",
+			Source:      "dist/app.zip",
+			Target:      "repo/app/",
+			BuildName:   "app",
+			BuildNumber: "42",
+			Module:      "frontend",
+		}
+		cmdArgs, err := buildCommand(args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, flag := range []string{"--build-name=app", "--build-number=42", "--module=frontend"} {
+			if !contains(cmdArgs, flag) {
+				t.Errorf("%s: want %s, got %q", command, flag, cmdArgs)
+			}
+		}
+	}
+}
+
+func TestBuildArgsModuleWithoutBuild(t *testing.T) {
+	var buf bytes.Buffer
+	logrus.SetOutput(&buf)
+	defer logrus.SetOutput(os.Stderr)
+
+	if got := buildArgs(Args{Module: "frontend"}); got != nil {
+		t.Errorf("Want no build flags without a build name, got %q", got)
+	}
+	if !strings.Contains(buf.String(), "Module is set without a build name") {
+		t.Errorf("Want a warning for the module without a build name, got %q", buf.String())
+	}
+}
+
+func TestBuildInfoDroneDefaults(t *testing.T) {
+	t.Setenv("DRONE_REPO_NAME", "hello-world")
+	t.Setenv("DRONE_BUILD_NUMBER", "12")
+
+	var args Args
+	if err := envconfig.Process("", &args); err != nil {
+		t.Fatal(err)
+	}
+	if name, number := buildInfo(args); name != "hello-world" || number != "12" {
+		t.Errorf("Want build hello-world 12 from the drone environment, got %s %s", name, number)
+	}
+
+	t.Setenv("PLUGIN_BUILD_NAME", "app")
+	t.Setenv("PLUGIN_BUILD_NUMBER", "42")
+	args = Args{}
+	if err := envconfig.Process("", &args); err != nil {
+		t.Fatal(err)
+	}
+	if name, number := buildInfo(args); name != "app" || number != "42" {
+		t.Errorf("Want the plugin settings to take precedence, got %s %s", name, number)
+	}
+}
+
+func TestCollectDepsCommand(t *testing.T) {
+	args := Args{
+		Command:     "collect-deps",
+		BuildName:   "app",
+		BuildNumber: "42",
+		Source:      "node_modules/**/*.tgz",
+	}
+	cmdArgs, err := buildCommand(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `rt bad app 42 node_modules/**/*.tgz`
+	if got := strings.Join(cmdArgs, " "); got != want {
+		t.Errorf("Want command\n%s\ngot\n%s", want, got)
+	}
+}
+
+func TestCollectDepsCommandErrors(t *testing.T) {
+	tests := []Args{
+		{BuildNumber: "42", Source: "lib/*.jar"},
+		{BuildName: "app", Source: "lib/*.jar"},
+		{BuildName: "app", BuildNumber: "42"},
+	}
+	for _, args := range tests {
+		args.Command = "collect-deps"
+		if _, err := buildCommand(args); err == nil {
+			t.Errorf("Expect error for build %q %q and source %q", args.BuildName, args.BuildNumber, args.Source)
+		}
+	}
+}
+
+func TestBuildAppendCommand(t *testing.T) {
+	args := Args{
+		Command:           "build-append",
+		BuildName:         "app",
+		BuildNumber:       "42",
+		AppendBuildName:   "app-frontend",
+		AppendBuildNumber: "17",
+	}
+	cmdArgs, err := buildCommand(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `rt ba app 42 app-frontend 17`
+	if got := strings.Join(cmdArgs, " "); got != want {
+		t.Errorf("Want command\n%s\ngot\n%s", want, got)
+	}
+}
+
+func TestBuildAppendCommandErrors(t *testing.T) {
+	tests := []Args{
+		{BuildNumber: "42", AppendBuildName: "app-frontend", AppendBuildNumber: "17"},
+		{BuildName: "app", AppendBuildName: "app-frontend", AppendBuildNumber: "17"},
+		{BuildName: "app", BuildNumber: "42", AppendBuildNumber: "17"},
+		{BuildName: "app", BuildNumber: "42", AppendBuildName: "app-frontend"},
+	}
+	for _, args := range tests {
+		args.Command = "build-append"
+		if _, err := buildCommand(args); err == nil {
+			t.Errorf("Expect error for build %q %q appending %q %q", args.BuildName, args.BuildNumber, args.AppendBuildName, args.AppendBuildNumber)
+		}
+	}
+}
+
+func TestPublishBuildCommandBuildURL(t *testing.T) {
+	t.Setenv("DRONE_BUILD_LINK", "https://drone.example.com/octocat/app/42")
+
+	var args Args
+	if err := envconfig.Process("", &args); err != nil {
+		t.Fatal(err)
+	}
+	args.Command = "publish-build"
+	args.URL = "https://artifactory.example.com"
+	args.APIKey = "key"
+	args.BuildName = "app"
+	args.BuildNumber = "42"
+	cmdArgs, err := buildCommand(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `rt bp --url=https://artifactory.example.com --apikey=key --build-url=https://drone.example.com/octocat/app/42 app 42`
+	if got := strings.Join(cmdArgs, " "); got != want {
+		t.Errorf("Want command\n%s\ngot\n%s", want, got)
+	}
+
+	args.BuildURL = "https://ci.example.com/builds/42"
+	cmdArgs, err = buildCommand(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !contains(cmdArgs, "--build-url=https://ci.example.com/builds/42") {
+		t.Errorf("Want the build url setting to take precedence, got %q", cmdArgs)
+	}
+}
+
+func TestExecPublishBuildInfo(t *testing.T) {
+	runner := useFakeRunner(t, nil)
+	args := Args{
+		URL:              "https://artifactory.example.com",
+		APIKey:           "key",
+		Source:           "dist/app.zip",
+		Target:           "repo/app/",
+		BuildName:        "app",
+		BuildNumber:      "42",
+		PublishBuildInfo: "true",
+	}
+	if err := Exec(context.Background(), args); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, call := range runner.calls {
+		got = append(got, strings.Join(call.args, " "))
+	}
+	want := []string{
+		"rt u --url=https://artifactory.example.com --apikey=key --build-name=app --build-number=42 dist/app.zip repo/app/",
+		"rt bp --url=https://artifactory.example.com --apikey=key app 42",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Want commands\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func TestExecPublishBuildInfoUploadFailure(t *testing.T) {
+	runner := useFakeRunner(t, func(call fakeCall) error {
+		if call.args[1] == "u" {
+			return errors.New("upload failed")
+		}
+		return nil
+	})
+	args := Args{
+		URL:              "https://artifactory.example.com",
+		APIKey:           "key",
+		Source:           "dist/app.zip",
+		Target:           "repo/app/",
+		BuildName:        "app",
+		BuildNumber:      "42",
+		PublishBuildInfo: "true",
+	}
+	if err := Exec(context.Background(), args); err == nil {
+		t.Error("Expect error when the upload fails")
+	}
+	for _, call := range runner.calls {
+		if isPublishBuild(call.args) {
+			t.Errorf("Want the build info publish skipped after a failed upload, got %q", call.args)
+		}
+	}
+}
+
+func TestBuildCommandsPublishBuildInfoDryRun(t *testing.T) {
+	args := Args{
+		URL:              "https://artifactory.example.com",
+		APIKey:           "key",
+		Source:           "dist/app.zip",
+		Target:           "repo/app/",
+		BuildName:        "app",
+		BuildNumber:      "42",
+		PublishBuildInfo: "true",
+		DryRun:           "true",
+	}
+	cmds, err := buildCommands(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cmds) != 1 {
+		t.Errorf("Want no build info publish for a dry run, got %d commands", len(cmds))
+	}
+}
+
+func TestBuildCommandsPublishBuildInfoSyncDeletes(t *testing.T) {
+	args := Args{
+		URL:              "https://artifactory.example.com",
+		APIKey:           "key",
+		Source:           "dist/app.zip",
+		Target:           "repo/app/",
+		BuildName:        "app",
+		BuildNumber:      "42",
+		PublishBuildInfo: "true",
+		SyncDeletes:      "repo/app/",
+	}
+	cmds, err := buildCommands(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cmds) != 1 {
+		t.Fatalf("Want no build info publish for an unconfirmed sync deletes, got %d commands", len(cmds))
+	}
+	if !contains(cmds[0], "--dry-run") {
+		t.Errorf("Want the upload to run as a dry run, got %q", cmds[0])
+	}
+
+	args.Quiet = "true"
+	cmds, err = buildCommands(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cmds) != 2 || !isPublishBuild(cmds[1]) {
+		t.Errorf("Want the build info published after a confirmed sync deletes, got %q", cmds)
+	}
+}
+
+func TestPromoteCommandSync(t *testing.T) {
+	args := Args{
+		Command:     "promote",
+		URL:         "https://artifactory.example.com",
+		APIKey:      "key",
+		BuildName:   "app",
+		BuildNumber: "42",
+		TargetRepo:  "libs-release",
+		Sync:        "true",
+	}
+	cmdArgs, err := buildCommand(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the promotion is synchronous and the cli has no sync flag
+	want := `rt bpr --url=https://artifactory.example.com --apikey=key app 42 libs-release`
+	if got := strings.Join(cmdArgs, " "); got != want {
+		t.Errorf("Want command\n%s\ngot\n%s", want, got)
+	}
+}
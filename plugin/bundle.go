@@ -0,0 +1,195 @@
+// Copyright 2020 the Drone Authors. All rights reserved.
+// Use of this source code is governed by the Blue Oak Model License
+// that can be found in the LICENSE file.
+
+package plugin
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// bundleCreateCommand returns the jfrog ds rbc arguments used to
+// create a release bundle from the spec or the source pattern.
+func bundleCreateCommand(args Args) ([]string, error) {
+	cmdArgs, err := baseCommand(args, "ds", "rbc")
+	if err != nil {
+		return nil, err
+	}
+	name, version, err := requireBundle(args)
+	if err != nil {
+		return nil, err
+	}
+	if parseBoolOrDefault(false, args.Sign) {
+		cmdArgs = append(cmdArgs, "--sign")
+		cmdArgs = append(cmdArgs, passphraseArgs(args)...)
+	}
+	cmdArgs = append(cmdArgs, dryRunArgs(args)...)
+	if args.Spec != "" {
+		specArgs, err := specArgs(args)
+		if err != nil {
+			return nil, err
+		}
+		cmdArgs = append(cmdArgs, specArgs...)
+		return append(cmdArgs, name, version), nil
+	}
+	if args.Source == "" {
+		return nil, fmt.Errorf("either spec or source needs to be set")
+	}
+	return append(cmdArgs, name, version, args.Source), nil
+}
+
+// bundleSignCommand returns the jfrog ds rbs arguments used to sign
+// a release bundle that was created without signing.
+func bundleSignCommand(args Args) ([]string, error) {
+	cmdArgs, err := baseCommand(args, "ds", "rbs")
+	if err != nil {
+		return nil, err
+	}
+	name, version, err := requireBundle(args)
+	if err != nil {
+		return nil, err
+	}
+	cmdArgs = append(cmdArgs, passphraseArgs(args)...)
+	return append(cmdArgs, name, version), nil
+}
+
+// bundleDistributeCommand returns the jfrog ds rbd arguments used to
+// distribute a release bundle to the edge nodes selected by the
+// distribution rules file or the site, city and country codes.
+func bundleDistributeCommand(args Args) ([]string, error) {
+	cmdArgs, err := baseCommand(args, "ds", "rbd")
+	if err != nil {
+		return nil, err
+	}
+	name, version, err := requireBundle(args)
+	if err != nil {
+		return nil, err
+	}
+	targetArgs, err := distTargetArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	cmdArgs = append(cmdArgs, targetArgs...)
+	cmdArgs = append(cmdArgs, syncArgs(args)...)
+	cmdArgs = append(cmdArgs, dryRunArgs(args)...)
+	return append(cmdArgs, name, version), nil
+}
+
+// bundleDeleteCommand returns the jfrog ds rbdel arguments used to
+// delete a release bundle from the edge nodes, and optionally from
+// distribution. The deletion needs to be confirmed, see confirmArgs.
+func bundleDeleteCommand(args Args) ([]string, error) {
+	cmdArgs, err := baseCommand(args, "ds", "rbdel")
+	if err != nil {
+		return nil, err
+	}
+	name, version, err := requireBundle(args)
+	if err != nil {
+		return nil, err
+	}
+	if args.DistRules != "" || args.Site != "" || args.City != "" || args.CountryCodes != "" {
+		targetArgs, err := distTargetArgs(args)
+		if err != nil {
+			return nil, err
+		}
+		cmdArgs = append(cmdArgs, targetArgs...)
+	}
+	if parseBoolOrDefault(false, args.DeleteFromDist) {
+		cmdArgs = append(cmdArgs, "--delete-from-dist")
+	}
+	cmdArgs = append(cmdArgs, syncArgs(args)...)
+
+	cmdArgs = append(cmdArgs, confirmArgs(args, "Release bundle delete is not confirmed, running as a dry run. Set quiet to true to delete the bundle.")...)
+	return append(cmdArgs, name, version), nil
+}
+
+// distTargetArgs returns the flags selecting the distribution
+// targets. Either the rules file or the inline targeting needs to
+// be set, but not both.
+func distTargetArgs(args Args) ([]string, error) {
+	inline := args.Site != "" || args.City != "" || args.CountryCodes != ""
+	switch {
+	case args.DistRules != "" && inline:
+		return nil, fmt.Errorf("either dist rules or site, city and country codes needs to be set, not both")
+	case args.DistRules != "":
+		if _, err := os.Stat(resolvePath(args, args.DistRules)); err != nil {
+			return nil, fmt.Errorf("dist rules file %q does not exist", args.DistRules)
+		}
+		return []string{fmt.Sprintf("--dist-rules=%s", args.DistRules)}, nil
+	case inline:
+		var cmdArgs []string
+		if args.Site != "" {
+			cmdArgs = append(cmdArgs, fmt.Sprintf("--site=%s", args.Site))
+		}
+		if args.City != "" {
+			cmdArgs = append(cmdArgs, fmt.Sprintf("--city=%s", args.City))
+		}
+		if args.CountryCodes != "" {
+			cmdArgs = append(cmdArgs, fmt.Sprintf("--country-codes=%s", args.CountryCodes))
+		}
+		return cmdArgs, nil
+	default:
+		return nil, fmt.Errorf("either dist rules or site, city or country codes needs to be set")
+	}
+}
+
+// syncArgs returns the flag used to wait for the operation to
+// complete, or nil if sync is not set. The wait is limited to the
+// timeout, rounded down to whole minutes with at least one minute,
+// so that the cli gives up before the command is killed.
+func syncArgs(args Args) []string {
+	if !parseBoolOrDefault(false, args.Sync) {
+		return nil
+	}
+	cmdArgs := []string{"--sync"}
+	if timeout, err := parseDuration(args.Timeout); err == nil && timeout > 0 {
+		minutes := int(timeout.Minutes())
+		if minutes < 1 {
+			minutes = 1
+		}
+		cmdArgs = append(cmdArgs, fmt.Sprintf("--max-wait-minutes=%d", minutes))
+	}
+	return cmdArgs
+}
+
+// passphraseArgs returns the gpg passphrase flag used to sign the
+// release bundle, or nil if no passphrase is set. The jfrog cli 2.x
+// only accepts the passphrase as the --passphrase flag, with no env
+// or file alternative, so the passphrase is visible in the process
+// arguments of the jfrog command while it runs. It is only masked in
+// the traced commands, logs and errors of the plugin.
+func passphraseArgs(args Args) []string {
+	if args.GPGPassphrase == "" {
+		return nil
+	}
+	return []string{fmt.Sprintf("--passphrase=%s", args.GPGPassphrase)}
+}
+
+// readPassphraseFile returns the gpg passphrase read from path,
+// without the trailing newline. The passphrase is not included in
+// any error.
+func readPassphraseFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("error reading passphrase file: %s", err)
+	}
+	passphrase := strings.TrimRight(string(data), "\r\n")
+	if passphrase == "" {
+		return "", fmt.Errorf("passphrase file %q is empty", path)
+	}
+	return passphrase, nil
+}
+
+// requireBundle returns the release bundle name and version, or
+// an error if either is missing.
+func requireBundle(args Args) (string, string, error) {
+	if args.BundleName == "" {
+		return "", "", fmt.Errorf("bundle name needs to be set")
+	}
+	if args.BundleVersion == "" {
+		return "", "", fmt.Errorf("bundle version needs to be set")
+	}
+	return args.BundleName, args.BundleVersion, nil
+}
@@ -0,0 +1,320 @@
+// Copyright 2020 the Drone Authors. All rights reserved.
+// Use of this source code is governed by the Blue Oak Model License
+// that can be found in the LICENSE file.
+
+package plugin
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestBundleCreateCommand(t *testing.T) {
+	tests := []struct {
+		name string
+		args Args
+		want string
+	}{
+		{
+			name: "source",
+			args: Args{
+				Source: "generic-local/app/*.zip",
+			},
+			want: `ds rbc --url=https://distribution.example.com --apikey=key app 1.0.0 generic-local/app/*.zip`,
+		},
+		{
+			name: "spec",
+			args: Args{
+				Spec: "testdata/spec.json",
+			},
+			want: `ds rbc --url=https://distribution.example.com --apikey=key --spec=testdata/spec.json app 1.0.0`,
+		},
+		{
+			name: "sign",
+			args: Args{
+				Source:        "generic-local/app/*.zip",
+				Sign:          "true",
+				GPGPassphrase: "secret",
+			},
+			want: `ds rbc --url=https://distribution.example.com --apikey=key --sign --passphrase=secret app 1.0.0 generic-local/app/*.zip`,
+		},
+	}
+	for _, test := range tests {
+		args := test.args
+		args.Command = "release-bundle-create"
+		args.URL = "https://distribution.example.com"
+		args.APIKey = "key"
+		args.BundleName = "app"
+		args.BundleVersion = "1.0.0"
+		cmdArgs, err := buildCommand(args)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.name, err)
+			continue
+		}
+		if got := strings.Join(cmdArgs, " "); got != test.want {
+			t.Errorf("%s: want command\n%s\ngot\n%s", test.name, test.want, got)
+		}
+	}
+}
+
+func TestBundleCreateCommandErrors(t *testing.T) {
+	args := Args{
+		Command: "release-bundle-create",
+		URL:     "https://distribution.example.com",
+		APIKey:  "key",
+		Source:  "generic-local/app/*.zip",
+	}
+	if _, err := buildCommand(args); err == nil {
+		t.Error("Expect error when bundle name is missing")
+	}
+	args.BundleName = "app"
+	if _, err := buildCommand(args); err == nil {
+		t.Error("Expect error when bundle version is missing")
+	}
+	args.BundleVersion = "1.0.0"
+	args.Source = ""
+	if _, err := buildCommand(args); err == nil {
+		t.Error("Expect error when neither spec nor source is set")
+	}
+}
+
+func TestBundleSignCommand(t *testing.T) {
+	args := Args{
+		Command:       "release-bundle-sign",
+		URL:           "https://distribution.example.com",
+		APIKey:        "key",
+		BundleName:    "app",
+		BundleVersion: "1.0.0",
+		GPGPassphrase: "secret",
+	}
+	cmdArgs, err := buildCommand(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `ds rbs --url=https://distribution.example.com --apikey=key --passphrase=secret app 1.0.0`
+	if got := strings.Join(cmdArgs, " "); got != want {
+		t.Errorf("Want command\n%s\ngot\n%s", want, got)
+	}
+	args.BundleVersion = ""
+	if _, err := buildCommand(args); err == nil {
+		t.Error("Expect error when bundle version is missing")
+	}
+}
+
+func TestBundleSignRedact(t *testing.T) {
+	args := Args{
+		Command:       "release-bundle-sign",
+		URL:           "https://distribution.example.com",
+		APIKey:        "key",
+		BundleName:    "app",
+		BundleVersion: "1.0.0",
+		GPGPassphrase: "secret",
+	}
+	cmdArgs, err := buildCommand(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := strings.Join(redact(cmdArgs, secrets(args)...), " ")
+	if strings.Contains(got, "secret") {
+		t.Errorf("Expect the passphrase to be masked, got %s", got)
+	}
+	if !strings.Contains(got, "--passphrase=****") {
+		t.Errorf("Want the masked passphrase flag, got %s", got)
+	}
+}
+
+func TestBundleDistributeCommand(t *testing.T) {
+	rules := filepath.Join(t.TempDir(), "rules.json")
+	if err := os.WriteFile(rules, []byte(`{"distribution_rules": []}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		args Args
+		want string
+	}{
+		{
+			name: "rules file",
+			args: Args{DistRules: rules, Sync: "true"},
+			want: `ds rbd --url=https://distribution.example.com --apikey=key --dist-rules=` + rules + ` --sync app 1.0.0`,
+		},
+		{
+			name: "sync with timeout",
+			args: Args{Site: "edge-*", Sync: "true", Timeout: "90s"},
+			want: `ds rbd --url=https://distribution.example.com --apikey=key --site=edge-* --sync --max-wait-minutes=1 app 1.0.0`,
+		},
+		{
+			name: "sync with short timeout",
+			args: Args{Site: "edge-*", Sync: "true", Timeout: "30s"},
+			want: `ds rbd --url=https://distribution.example.com --apikey=key --site=edge-* --sync --max-wait-minutes=1 app 1.0.0`,
+		},
+		{
+			name: "sync with whole minute timeout",
+			args: Args{Site: "edge-*", Sync: "true", Timeout: "10m"},
+			want: `ds rbd --url=https://distribution.example.com --apikey=key --site=edge-* --sync --max-wait-minutes=10 app 1.0.0`,
+		},
+		{
+			name: "inline",
+			args: Args{Site: "edge-*", City: "Berlin", CountryCodes: "DE,FR"},
+			want: `ds rbd --url=https://distribution.example.com --apikey=key --site=edge-* --city=Berlin --country-codes=DE,FR app 1.0.0`,
+		},
+	}
+	for _, test := range tests {
+		args := test.args
+		args.Command = "release-bundle-distribute"
+		args.URL = "https://distribution.example.com"
+		args.APIKey = "key"
+		args.BundleName = "app"
+		args.BundleVersion = "1.0.0"
+		cmdArgs, err := buildCommand(args)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.name, err)
+			continue
+		}
+		if got := strings.Join(cmdArgs, " "); got != test.want {
+			t.Errorf("%s: want command\n%s\ngot\n%s", test.name, test.want, got)
+		}
+	}
+}
+
+func TestBundleDistributeCommandErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		args Args
+	}{
+		{name: "no targets", args: Args{}},
+		{name: "rules and inline", args: Args{DistRules: "testdata/spec.json", Site: "edge-*"}},
+		{name: "missing rules", args: Args{DistRules: "testdata/missing.json"}},
+	}
+	for _, test := range tests {
+		args := test.args
+		args.Command = "release-bundle-distribute"
+		args.URL = "https://distribution.example.com"
+		args.APIKey = "key"
+		args.BundleName = "app"
+		args.BundleVersion = "1.0.0"
+		if _, err := buildCommand(args); err == nil {
+			t.Errorf("%s: expect error", test.name)
+		}
+	}
+}
+
+func TestBundleDeleteCommand(t *testing.T) {
+	tests := []struct {
+		name string
+		args Args
+		want string
+	}{
+		{
+			name: "unconfirmed",
+			args: Args{},
+			want: `ds rbdel --url=https://distribution.example.com --apikey=key --dry-run app 1.0.0`,
+		},
+		{
+			name: "confirmed",
+			args: Args{Quiet: "true", DeleteFromDist: "true", Sync: "true"},
+			want: `ds rbdel --url=https://distribution.example.com --apikey=key --delete-from-dist --sync --quiet app 1.0.0`,
+		},
+		{
+			name: "confirmed dry run",
+			args: Args{Quiet: "true", DryRun: "true", Site: "edge-*"},
+			want: `ds rbdel --url=https://distribution.example.com --apikey=key --site=edge-* --dry-run app 1.0.0`,
+		},
+	}
+	for _, test := range tests {
+		args := test.args
+		args.Command = "release-bundle-delete"
+		args.URL = "https://distribution.example.com"
+		args.APIKey = "key"
+		args.BundleName = "app"
+		args.BundleVersion = "1.0.0"
+		cmdArgs, err := buildCommand(args)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.name, err)
+			continue
+		}
+		if got := strings.Join(cmdArgs, " "); got != test.want {
+			t.Errorf("%s: want command\n%s\ngot\n%s", test.name, test.want, got)
+		}
+	}
+}
+
+func TestExecPassphraseFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "passphrase")
+	if err := os.WriteFile(path, []byte("s3cr3t-passphrase\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	runner := useFakeRunner(t, func(call fakeCall) error {
+		return errors.New("exit status 1: s3cr3t-passphrase")
+	})
+
+	// capture the traced command written to stdout
+	stdout := os.Stdout
+	f, err := os.Create(filepath.Join(t.TempDir(), "stdout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	os.Stdout = f
+	defer func() { os.Stdout = stdout }()
+
+	args := Args{
+		Command:        "release-bundle-sign",
+		URL:            "https://distribution.example.com",
+		APIKey:         "key",
+		BundleName:     "app",
+		BundleVersion:  "1.0.0",
+		PassphraseFile: path,
+	}
+	err = Exec(context.Background(), args)
+	os.Stdout = stdout
+	if err == nil {
+		t.Fatal("Expect error from the failed command")
+	}
+	if strings.Contains(err.Error(), "s3cr3t-passphrase") {
+		t.Errorf("Expect the passphrase masked in the error, got %s", err)
+	}
+	if len(runner.calls) != 1 || !contains(runner.calls[0].args, "--passphrase=s3cr3t-passphrase") {
+		t.Fatalf("Want the passphrase read from the file, got %q", runner.calls)
+	}
+	traced, err := os.ReadFile(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(traced), "s3cr3t-passphrase") {
+		t.Errorf("Expect the passphrase masked in the traced command, got %s", traced)
+	}
+	if !strings.Contains(string(traced), "--passphrase=****") {
+		t.Errorf("Want the masked passphrase flag in the traced command, got %s", traced)
+	}
+}
+
+func TestReadPassphraseFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "passphrase")
+	if err := os.WriteFile(path, []byte("secret\r\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	got, err := readPassphraseFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != "secret" {
+		t.Errorf("Want passphrase without the trailing newline, got %q", got)
+	}
+
+	empty := filepath.Join(dir, "empty")
+	if err := os.WriteFile(empty, []byte("\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := readPassphraseFile(empty); err == nil {
+		t.Error("Expect error for an empty passphrase file")
+	}
+	if _, err := readPassphraseFile(filepath.Join(dir, "missing")); err == nil {
+		t.Error("Expect error for a missing passphrase file")
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright 2020 the Drone Authors. All rights reserved.
+// Use of this source code is governed by the Blue Oak Model License
+// that can be found in the LICENSE file.
+
+package plugin
+
+import (
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// writePEMFile writes the pem file contents to disk so that the
+// jfrog cli trusts the server certificate. When the contents hold
+// several certificates, each one is written to its own file in
+// the certs directory. It returns the paths of the files it created,
+// without the existing files it replaced, so that a certificate that
+// was there before the run is not removed with the run.
+func writePEMFile(args Args) ([]string, error) {
+	if args.PEMFileContents == "" {
+		return nil, nil
+	}
+	if parseBoolOrDefault(false, args.Insecure) {
+		logrus.Warnln("Insecure is set, ignoring the pem file contents. Insecure TLS disables certificate verification.")
+		return nil, nil
+	}
+	path := args.PEMFilePath
+	if path == "" {
+		path = defaultPEMPath(args)
+	}
+	var paths []string
+	certs := pemCerts(args.PEMFileContents)
+	for i, cert := range certs {
+		_, err := os.Stat(pemPath(path, i))
+		existed := err == nil
+		if err := writeCert(pemPath(path, i), cert); err != nil {
+			return paths, err
+		}
+		if !existed {
+			paths = append(paths, pemPath(path, i))
+		}
+	}
+	return paths, nil
+}
+
+// trustPEMFile writes the pem file contents to the certs directory
+// of the config home as well, when they are written to an explicit
+// pem file path outside of it. The jfrog cli only trusts the
+// certificates in the certs directory of its home. It returns the
+// paths of the files it created.
+func trustPEMFile(args Args) ([]string, error) {
+	if args.PEMFilePath == "" {
+		return nil, nil
+	}
+	if filepath.Dir(filepath.Clean(args.PEMFilePath)) == filepath.Dir(defaultPEMPath(args)) {
+		return nil, nil
+	}
+	args.PEMFilePath = ""
+	return writePEMFile(args)
+}
+
+// defaultPEMPath returns the path of the pem file in the jfrog
+// certs directory. The directory is based on the config home,
+// JFROG_CLI_HOME or the user's home directory, falling back to
+// the container defaults.
+func defaultPEMPath(args Args) string {
+	if args.ConfigHome != "" {
+		return filepath.Join(args.ConfigHome, "security", "certs", "cert.pem")
+	}
+	home := os.Getenv("JFROG_CLI_HOME")
+	if home == "" {
+		home, _ = os.UserHomeDir()
+	}
+	if home == "" {
+		if runtime.GOOS == "windows" {
+			return "C:/users/ContainerAdministrator/.jfrog/security/certs/cert.pem"
+		}
+		return "/root/.jfrog/security/certs/cert.pem"
+	}
+	return filepath.Join(home, ".jfrog", "security", "certs", "cert.pem")
+}
+
+// writeCert writes a single certificate to path, replacing any
+// existing file so that a rotated certificate is picked up.
+func writeCert(path string, cert []byte) error {
+	logrus.Debugf("Creating pem file at %q", path)
+	// remove filename from path
+	dir := filepath.Dir(path)
+	pemFolderErr := os.MkdirAll(dir, 0700)
+	if pemFolderErr != nil {
+		return fmt.Errorf("error creating pem folder: %s", pemFolderErr)
+	}
+	// write pem contents
+	pemWriteErr := os.WriteFile(path, cert, 0600)
+	if pemWriteErr != nil {
+		return fmt.Errorf("error writing pem file: %s", pemWriteErr)
+	}
+	// an existing file keeps its mode, so reset it
+	if err := os.Chmod(path, 0600); err != nil {
+		return fmt.Errorf("error writing pem file: %s", err)
+	}
+	logrus.Infof("Successfully created pem file at %q", path)
+	return nil
+}
+
+// pemCerts splits the pem file contents into its pem blocks. The
+// contents are returned unchanged when they hold at most one block.
+func pemCerts(contents string) [][]byte {
+	var certs [][]byte
+	rest := []byte(contents)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		certs = append(certs, pem.EncodeToMemory(block))
+	}
+	if len(certs) <= 1 {
+		return [][]byte{[]byte(contents)}
+	}
+	return certs
+}
+
+// pemPath returns the path of the i-th certificate. The first
+// certificate is written to path, the others get a numbered
+// suffix, e.g. cert-1.pem.
+func pemPath(path string, i int) string {
+	if i == 0 {
+		return path
+	}
+	ext := filepath.Ext(path)
+	return fmt.Sprintf("%s-%d%s", strings.TrimSuffix(path, ext), i, ext)
+}
@@ -0,0 +1,189 @@
+// Copyright 2020 the Drone Authors. All rights reserved.
+// Use of this source code is governed by the Blue Oak Model License
+// that can be found in the LICENSE file.
+
+package plugin
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	testCertA = "-----BEGIN CERTIFICATE-----\nY2VydGlmaWNhdGUgYQ==\n-----END CERTIFICATE-----\n"
+	testCertB = "-----BEGIN CERTIFICATE-----\nY2VydGlmaWNhdGUgYg==\n-----END CERTIFICATE-----\n"
+)
+
+func TestWritePEMFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "certs", "cert.pem")
+	args := Args{PEMFileContents: testCertA, PEMFilePath: path}
+	if _, err := writePEMFile(args); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != testCertA {
+		t.Errorf("Want pem file %q, got %q", testCertA, got)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("Want pem file mode 0600, got %o", perm)
+	}
+}
+
+func TestWritePEMFileMultiple(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "certs")
+	args := Args{
+		PEMFileContents: testCertA + testCertB,
+		PEMFilePath:     filepath.Join(dir, "cert.pem"),
+	}
+	if _, err := writePEMFile(args); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{
+		"cert.pem":   testCertA,
+		"cert-1.pem": testCertB,
+	}
+	for name, cert := range want {
+		got, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(got) != cert {
+			t.Errorf("Want %s to contain %q, got %q", name, cert, got)
+		}
+	}
+	info, err := os.Stat(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0700 {
+		t.Errorf("Want certs directory mode 0700, got %o", perm)
+	}
+}
+
+func TestWritePEMFileOverwrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cert.pem")
+	if err := os.WriteFile(path, []byte("stale"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	args := Args{PEMFileContents: testCertA, PEMFilePath: path}
+	paths, err := writePEMFile(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 0 {
+		t.Errorf("Want no created paths for a replaced pem file, got %q", paths)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != testCertA {
+		t.Errorf("Want stale pem file replaced with %q, got %q", testCertA, got)
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("Want pem file mode 0600, got %o", perm)
+	}
+}
+
+func TestWritePEMFileInsecure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cert.pem")
+	var buf bytes.Buffer
+	logrus.SetOutput(&buf)
+	defer logrus.SetOutput(os.Stderr)
+
+	args := Args{PEMFileContents: testCertA, PEMFilePath: path, Insecure: "true"}
+	if _, err := writePEMFile(args); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("Want no pem file when insecure is set")
+	}
+	if !strings.Contains(buf.String(), "disables certificate verification") {
+		t.Errorf("Want a warning that insecure disables verification, got %q", buf.String())
+	}
+}
+
+func TestWritePEMFileCLIHome(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("JFROG_CLI_HOME", home)
+
+	if _, err := writePEMFile(Args{PEMFileContents: testCertA}); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(home, ".jfrog", "security", "certs", "cert.pem")
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("Want pem file under JFROG_CLI_HOME: %s", err)
+	}
+}
+
+func TestTrustPEMFile(t *testing.T) {
+	home := t.TempDir()
+	path := filepath.Join(t.TempDir(), "cert.pem")
+
+	args := Args{PEMFileContents: testCertA, PEMFilePath: path, ConfigHome: home}
+	paths, err := trustPEMFile(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{filepath.Join(home, "security", "certs", "cert.pem")}
+	if !reflect.DeepEqual(paths, want) {
+		t.Errorf("Want pem file copied to %q, got %q", want, paths)
+	}
+
+	args.PEMFilePath = want[0]
+	if paths, err := trustPEMFile(args); err != nil || paths != nil {
+		t.Errorf("Want no copy for a pem file in the certs directory, got %q, %v", paths, err)
+	}
+	if paths, err := trustPEMFile(Args{PEMFileContents: testCertA, ConfigHome: home}); err != nil || paths != nil {
+		t.Errorf("Want no copy for the default pem file path, got %q, %v", paths, err)
+	}
+}
+
+func TestDefaultPEMPath(t *testing.T) {
+	t.Setenv("JFROG_CLI_HOME", "")
+	t.Setenv("HOME", "/home/drone")
+	want := filepath.Join("/home/drone", ".jfrog", "security", "certs", "cert.pem")
+	if got := defaultPEMPath(Args{}); got != want {
+		t.Errorf("Want pem path %q from the home directory, got %q", want, got)
+	}
+
+	want = filepath.Join("/tmp/jfrog", "security", "certs", "cert.pem")
+	if got := defaultPEMPath(Args{ConfigHome: "/tmp/jfrog"}); got != want {
+		t.Errorf("Want pem path %q from the config home, got %q", want, got)
+	}
+}
+
+func TestPEMPath(t *testing.T) {
+	tests := []struct {
+		path string
+		i    int
+		want string
+	}{
+		{"/certs/cert.pem", 0, "/certs/cert.pem"},
+		{"/certs/cert.pem", 1, "/certs/cert-1.pem"},
+		{"/certs/cert.pem", 2, "/certs/cert-2.pem"},
+		{"/certs/cert", 1, "/certs/cert-1"},
+	}
+	for _, test := range tests {
+		if got := pemPath(test.path, test.i); got != test.want {
+			t.Errorf("Want path %q for %q and %d, got %q", test.want, test.path, test.i, got)
+		}
+	}
+}
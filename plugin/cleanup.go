@@ -0,0 +1,33 @@
+// Copyright 2020 the Drone Authors. All rights reserved.
+// Use of this source code is governed by the Blue Oak Model License
+// that can be found in the LICENSE file.
+
+package plugin
+
+import (
+	"os"
+
+	"github.com/sirupsen/logrus"
+)
+
+// cleanup tracks the files and directories created by the run.
+type cleanup []string
+
+// add tracks the paths for removal.
+func (c *cleanup) add(paths ...string) {
+	*c = append(*c, paths...)
+}
+
+// remove removes the tracked paths, the most recently created
+// first. Errors are logged, as the run result is already known.
+func (c *cleanup) remove() {
+	paths := *c
+	for i := len(paths) - 1; i >= 0; i-- {
+		if err := os.RemoveAll(paths[i]); err != nil {
+			logrus.Warnf("Error removing %q: %s", paths[i], err)
+			continue
+		}
+		logrus.Debugf("Removed %q", paths[i])
+	}
+	*c = nil
+}
@@ -0,0 +1,76 @@
+// Copyright 2020 the Drone Authors. All rights reserved.
+// Use of this source code is governed by the Blue Oak Model License
+// that can be found in the LICENSE file.
+
+package plugin
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCleanup(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "cert.pem")
+	if err := os.WriteFile(file, []byte(testCertA), 0600); err != nil {
+		t.Fatal(err)
+	}
+	var created cleanup
+	created.add(file, filepath.Join(dir, "missing.pem"))
+	created.remove()
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Errorf("Want %s removed", file)
+	}
+	if _, err := os.Stat(dir); err != nil {
+		t.Errorf("Want the untracked directory left in place: %s", err)
+	}
+}
+
+func TestExecCleanupPEMFile(t *testing.T) {
+	home := t.TempDir()
+	path := filepath.Join(home, "security", "certs", "cert.pem")
+	useFakeRunner(t, func(call fakeCall) error {
+		if _, err := os.Stat(path); err != nil {
+			t.Errorf("Want the pem file to exist while running: %s", err)
+		}
+		return nil
+	})
+	args := Args{
+		URL:             "https://artifactory.example.com",
+		APIKey:          "key",
+		Source:          "dist/app.zip",
+		Target:          "repo/app/",
+		ConfigHome:      home,
+		PEMFileContents: testCertA,
+	}
+	if err := Exec(context.Background(), args); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("Want the pem file %s removed after running", path)
+	}
+	if _, err := os.Stat(home); err != nil {
+		t.Errorf("Want the user config home left in place: %s", err)
+	}
+}
+
+func TestExecCleanupKeepsPEMFilePath(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cert.pem")
+	useFakeRunner(t, nil)
+	args := Args{
+		URL:             "https://artifactory.example.com",
+		APIKey:          "key",
+		Source:          "dist/app.zip",
+		Target:          "repo/app/",
+		PEMFileContents: testCertA,
+		PEMFilePath:     path,
+	}
+	if err := Exec(context.Background(), args); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("Want the explicit pem file path left in place: %s", err)
+	}
+}
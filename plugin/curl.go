@@ -0,0 +1,103 @@
+// Copyright 2020 the Drone Authors. All rights reserved.
+// Use of this source code is governed by the Blue Oak Model License
+// that can be found in the LICENSE file.
+
+package plugin
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// curlCommands returns the jfrog config add arguments used to
+// configure the server, followed by the jfrog rt curl arguments
+// used to call the artifactory rest api. The server is configured
+// in the config home of the run.
+func curlCommands(args Args) ([][]string, error) {
+	curlArgs, err := splitFields(args.CurlArgs)
+	if err != nil {
+		return nil, err
+	}
+	if len(curlArgs) == 0 {
+		return nil, fmt.Errorf("curl args needs to be set")
+	}
+	for _, arg := range curlArgs {
+		if strings.HasPrefix(arg, "--server-id") {
+			return nil, fmt.Errorf("curl args must not set the server id")
+		}
+	}
+	return curl(args, curlArgs...)
+}
+
+// aqlCommands returns the commands used to post the aql query to
+// the search api. The query is either inline or read from a file.
+func aqlCommands(args Args) ([][]string, error) {
+	var data string
+	switch {
+	case args.AQLQuery != "" && args.AQLFile != "":
+		return nil, fmt.Errorf("either aql or aql file needs to be set, not both")
+	case args.AQLQuery != "":
+		data = args.AQLQuery
+	case args.AQLFile != "":
+		if _, err := os.Stat(resolvePath(args, args.AQLFile)); err != nil {
+			return nil, fmt.Errorf("aql file %q does not exist", args.AQLFile)
+		}
+		data = "@" + args.AQLFile
+	default:
+		return nil, fmt.Errorf("either aql or aql file needs to be set")
+	}
+	return curl(args, "-XPOST", "-H", "Content-Type: text/plain", "-d", data, "/api/search/aql")
+}
+
+// curl returns the commands used to configure the server and run
+// jfrog rt curl with the curl arguments.
+func curl(args Args, curlArgs ...string) ([][]string, error) {
+	configArgs, err := configCommand(args)
+	if err != nil {
+		return nil, err
+	}
+	cmdArgs := []string{"rt", "curl", fmt.Sprintf("--server-id=%s", serverID(args))}
+	return [][]string{configArgs, append(cmdArgs, curlArgs...)}, nil
+}
+
+// splitFields splits s into fields separated by white space. Single
+// or double quotes group a field that contains white space.
+func splitFields(s string) ([]string, error) {
+	var (
+		fields []string
+		field  strings.Builder
+		quote  rune
+		inside bool
+	)
+	for _, r := range s {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				field.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			inside = true
+		case unicode.IsSpace(r):
+			if inside {
+				fields = append(fields, field.String())
+				field.Reset()
+				inside = false
+			}
+		default:
+			field.WriteRune(r)
+			inside = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote in %q", s)
+	}
+	if inside {
+		fields = append(fields, field.String())
+	}
+	return fields, nil
+}
@@ -0,0 +1,158 @@
+// Copyright 2020 the Drone Authors. All rights reserved.
+// Use of this source code is governed by the Blue Oak Model License
+// that can be found in the LICENSE file.
+
+package plugin
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestCurlCommands(t *testing.T) {
+	args := Args{
+		Command:  "curl",
+		URL:      "https://artifactory.example.com",
+		Username: "foo",
+		Password: "bar",
+		CurlArgs: `-XPOST -H "Content-Type: text/plain" -d 'items.find()' /api/search/aql`,
+	}
+	cmds, err := buildCommands(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{
+		{"config", "add", "drone", "--artifactory-url=https://artifactory.example.com", "--user=foo", "--password=bar", "--interactive=false", "--overwrite=true"},
+		{"rt", "curl", "--server-id=drone", "-XPOST", "-H", "Content-Type: text/plain", "-d", "items.find()", "/api/search/aql"},
+	}
+	if !reflect.DeepEqual(cmds, want) {
+		t.Errorf("Want commands %q, got %q", want, cmds)
+	}
+}
+
+func TestCurlCommandsErrors(t *testing.T) {
+	tests := []string{
+		"",
+		"--server-id=other /api/system/ping",
+		`-H "Accept: text/plain /api/system/ping`,
+	}
+	for _, curlArgs := range tests {
+		args := Args{
+			Command:  "curl",
+			URL:      "https://artifactory.example.com",
+			APIKey:   "key",
+			CurlArgs: curlArgs,
+		}
+		if _, err := buildCommands(args); err == nil {
+			t.Errorf("Expect error for curl args %q", curlArgs)
+		}
+	}
+}
+
+func TestSplitFields(t *testing.T) {
+	tests := []struct {
+		in   string
+		want []string
+	}{
+		{in: "", want: nil},
+		{in: "  -XGET   /api/system/ping ", want: []string{"-XGET", "/api/system/ping"}},
+		{in: `-H "X-Name: a b" /api`, want: []string{"-H", "X-Name: a b", "/api"}},
+		{in: `-d 'say "hi"'`, want: []string{"-d", `say "hi"`}},
+		{in: `-d ""`, want: []string{"-d", ""}},
+	}
+	for _, test := range tests {
+		got, err := splitFields(test.in)
+		if err != nil {
+			t.Errorf("Unexpected error splitting %q: %s", test.in, err)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("Want fields %q for %q, got %q", test.want, test.in, got)
+		}
+	}
+}
+
+func TestAQLCommands(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "query.aql")
+	if err := os.WriteFile(file, []byte(`items.find({"repo": "generic-local"})`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		args Args
+		data string
+	}{
+		{
+			args: Args{AQLQuery: `items.find({"repo": "generic-local"})`},
+			data: `items.find({"repo": "generic-local"})`,
+		},
+		{
+			args: Args{AQLFile: file},
+			data: "@" + file,
+		},
+	}
+	for _, test := range tests {
+		args := test.args
+		args.Command = "aql"
+		args.URL = "https://artifactory.example.com"
+		args.APIKey = "key"
+		cmds, err := buildCommands(args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(cmds) != 2 {
+			t.Fatalf("Want config and curl commands, got %q", cmds)
+		}
+		want := []string{"rt", "curl", "--server-id=drone", "-XPOST", "-H", "Content-Type: text/plain", "-d", test.data, "/api/search/aql"}
+		if !reflect.DeepEqual(cmds[1], want) {
+			t.Errorf("Want command %q, got %q", want, cmds[1])
+		}
+	}
+}
+
+func TestAQLCommandsErrors(t *testing.T) {
+	tests := []Args{
+		{},
+		{AQLQuery: "items.find()", AQLFile: "testdata/query.aql"},
+		{AQLFile: "testdata/missing.aql"},
+	}
+	for _, args := range tests {
+		args.Command = "aql"
+		args.URL = "https://artifactory.example.com"
+		args.APIKey = "key"
+		if _, err := buildCommands(args); err == nil {
+			t.Errorf("Expect error for aql %q and aql file %q", args.AQLQuery, args.AQLFile)
+		}
+	}
+}
+
+func TestExecAQLOutputFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "results.json")
+	useFakeRunner(t, func(call fakeCall) error {
+		if call.args[0] == "rt" {
+			_, err := io.WriteString(call.stdout, `{"results": []}`)
+			return err
+		}
+		return nil
+	})
+
+	args := Args{
+		Command:    "aql",
+		URL:        "https://artifactory.example.com",
+		APIKey:     "key",
+		AQLQuery:   "items.find()",
+		OutputFile: path,
+	}
+	if err := Exec(context.Background(), args); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `{"results": []}`; string(got) != want {
+		t.Errorf("Want output file %q, got %q", want, got)
+	}
+}
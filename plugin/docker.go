@@ -0,0 +1,43 @@
+// Copyright 2020 the Drone Authors. All rights reserved.
+// Use of this source code is governed by the Blue Oak Model License
+// that can be found in the LICENSE file.
+
+package plugin
+
+import "fmt"
+
+// dockerPushCommand returns the jfrog rt docker-push arguments used
+// to push the image to the target repository.
+func dockerPushCommand(args Args) ([]string, error) {
+	cmdArgs, err := baseCommand(args, "rt", "docker-push")
+	if err != nil {
+		return nil, err
+	}
+	if args.ImageTag == "" {
+		return nil, fmt.Errorf("image tag needs to be set")
+	}
+	if args.TargetRepo == "" {
+		return nil, fmt.Errorf("target repo needs to be set")
+	}
+	cmdArgs = append(cmdArgs, buildArgs(args)...)
+	cmdArgs = append(cmdArgs, projectArgs(args)...)
+	return append(cmdArgs, args.ImageTag, args.TargetRepo), nil
+}
+
+// dockerPullCommand returns the jfrog rt docker-pull arguments used
+// to pull the image from the source repository.
+func dockerPullCommand(args Args) ([]string, error) {
+	cmdArgs, err := baseCommand(args, "rt", "docker-pull")
+	if err != nil {
+		return nil, err
+	}
+	if args.ImageTag == "" {
+		return nil, fmt.Errorf("image tag needs to be set")
+	}
+	if args.SourceRepo == "" {
+		return nil, fmt.Errorf("source repo needs to be set")
+	}
+	cmdArgs = append(cmdArgs, buildArgs(args)...)
+	cmdArgs = append(cmdArgs, projectArgs(args)...)
+	return append(cmdArgs, args.ImageTag, args.SourceRepo), nil
+}
@@ -0,0 +1,80 @@
+// Copyright 2020 the Drone Authors. All rights reserved.
+// Use of this source code is governed by the Blue Oak Model License
+// that can be found in the LICENSE file.
+
+package plugin
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestDockerPushCommand(t *testing.T) {
+	args := Args{
+		Command:     "docker-push",
+		URL:         "https://artifactory.example.com",
+		APIKey:      "key",
+		ImageTag:    "artifactory.example.com/docker/app:1.0",
+		TargetRepo:  "docker-local",
+		BuildName:   "app",
+		BuildNumber: "42",
+	}
+	cmdArgs, err := buildCommand(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `rt docker-push --url=https://artifactory.example.com --apikey=key --build-name=app --build-number=42 artifactory.example.com/docker/app:1.0 docker-local`
+	if got := strings.Join(cmdArgs, " "); got != want {
+		t.Errorf("Want command\n%s\ngot\n%s", want, got)
+	}
+}
+
+func TestDockerPushCommandErrors(t *testing.T) {
+	args := Args{
+		Command: "docker-push",
+		URL:     "https://artifactory.example.com",
+		APIKey:  "key",
+	}
+	if _, err := buildCommand(args); err == nil {
+		t.Error("Expect error when image tag is missing")
+	}
+	args.ImageTag = "artifactory.example.com/docker/app:1.0"
+	if _, err := buildCommand(args); err == nil {
+		t.Error("Expect error when target repo is missing")
+	}
+}
+
+func TestDockerPullCommand(t *testing.T) {
+	args := Args{
+		Command:     "docker-pull",
+		URL:         "https://artifactory.example.com",
+		APIKey:      "key",
+		ImageTag:    "artifactory.example.com/docker/app:1.0",
+		SourceRepo:  "docker-remote",
+		BuildName:   "app",
+		BuildNumber: "42",
+	}
+	cmdArgs, err := buildCommand(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `rt docker-pull --url=https://artifactory.example.com --apikey=key --build-name=app --build-number=42 artifactory.example.com/docker/app:1.0 docker-remote`
+	if got := strings.Join(cmdArgs, " "); got != want {
+		t.Errorf("Want command\n%s\ngot\n%s", want, got)
+	}
+}
+
+func TestDockerPullCommandErrors(t *testing.T) {
+	args := Args{
+		Command: "docker-pull",
+		URL:     "https://artifactory.example.com",
+		APIKey:  "key",
+	}
+	if _, err := buildCommand(args); err == nil || err.Error() != "image tag needs to be set" {
+		t.Errorf("Expect error when image tag is missing, got %v", err)
+	}
+	args.ImageTag = "artifactory.example.com/docker/app:1.0"
+	if _, err := buildCommand(args); err == nil || err.Error() != "source repo needs to be set" {
+		t.Errorf("Expect error when source repo is missing, got %v", err)
+	}
+}
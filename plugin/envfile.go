@@ -0,0 +1,122 @@
+// Copyright 2020 the Drone Authors. All rights reserved.
+// Use of this source code is governed by the Blue Oak Model License
+// that can be found in the LICENSE file.
+
+package plugin
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// envVar is a single variable of an env file.
+type envVar struct {
+	key   string
+	value string
+}
+
+// LoadEnvFile loads the variables of a .env style file into the
+// environment, so that the plugin can run outside of drone. Variables
+// that are already set in the environment are not overridden.
+func LoadEnvFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("error reading env file: %s", err)
+	}
+	vars, err := parseEnvFile(data)
+	if err != nil {
+		return err
+	}
+	for _, v := range vars {
+		if _, ok := os.LookupEnv(v.key); ok {
+			continue
+		}
+		if err := os.Setenv(v.key, v.value); err != nil {
+			return fmt.Errorf("error setting %s: %s", v.key, err)
+		}
+	}
+	return nil
+}
+
+// parseEnvFile parses a file with one KEY=value pair per line. Blank
+// lines, lines starting with # and an optional export prefix are
+// ignored. Values can be single quoted, taken as is, or double
+// quoted, with \n, \" and \\ escapes. A # preceded by white space
+// starts a comment in an unquoted value.
+func parseEnvFile(data []byte) ([]envVar, error) {
+	var vars []envVar
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" || strings.ContainsAny(key, " \t") {
+			return nil, fmt.Errorf("invalid env file line %d, expected KEY=value", n)
+		}
+		value, err := parseEnvValue(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid env file line %d: %s", n, err)
+		}
+		vars = append(vars, envVar{key: key, value: value})
+	}
+	return vars, scanner.Err()
+}
+
+// parseEnvValue returns the unquoted value.
+func parseEnvValue(s string) (string, error) {
+	switch {
+	case strings.HasPrefix(s, "'"):
+		end := strings.Index(s[1:], "'")
+		if end == -1 {
+			return "", fmt.Errorf("unterminated single quote")
+		}
+		return s[1 : end+1], checkEnvComment(s[end+2:])
+	case strings.HasPrefix(s, `"`):
+		var value strings.Builder
+		for i := 1; i < len(s); i++ {
+			switch c := s[i]; {
+			case c == '\\' && i+1 < len(s):
+				i++
+				switch s[i] {
+				case 'n':
+					value.WriteByte('\n')
+				case '"', '\\':
+					value.WriteByte(s[i])
+				default:
+					value.WriteByte('\\')
+					value.WriteByte(s[i])
+				}
+			case c == '"':
+				return value.String(), checkEnvComment(s[i+1:])
+			default:
+				value.WriteByte(c)
+			}
+		}
+		return "", fmt.Errorf("unterminated double quote")
+	default:
+		if i := strings.Index(s, " #"); i != -1 {
+			s = s[:i]
+		}
+		if i := strings.Index(s, "\t#"); i != -1 {
+			s = s[:i]
+		}
+		return strings.TrimSpace(s), nil
+	}
+}
+
+// checkEnvComment returns an error if anything other than a
+// comment follows a quoted value.
+func checkEnvComment(s string) error {
+	s = strings.TrimSpace(s)
+	if s != "" && !strings.HasPrefix(s, "#") {
+		return fmt.Errorf("unexpected %q after the quoted value", s)
+	}
+	return nil
+}
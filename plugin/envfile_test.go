@@ -0,0 +1,85 @@
+// Copyright 2020 the Drone Authors. All rights reserved.
+// Use of this source code is governed by the Blue Oak Model License
+// that can be found in the LICENSE file.
+
+package plugin
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseEnvFile(t *testing.T) {
+	data := []byte(`# artifactory settings
+PLUGIN_URL=https://artifactory.example.com
+export PLUGIN_USERNAME = drone
+PLUGIN_SOURCE=dist/*.zip # release archives
+
+PLUGIN_TARGET="repo/app with spaces/"
+PLUGIN_SPEC_VARS='a=b;#c=d' # kept as is
+PLUGIN_PEM_FILE_CONTENTS="line 1\nline 2 \"quoted\" \\ #not a comment"
+PLUGIN_FLAT=
+PLUGIN_EXCLUSIONS=*.tmp#not a comment
+`)
+	got, err := parseEnvFile(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []envVar{
+		{key: "PLUGIN_URL", value: "https://artifactory.example.com"},
+		{key: "PLUGIN_USERNAME", value: "drone"},
+		{key: "PLUGIN_SOURCE", value: "dist/*.zip"},
+		{key: "PLUGIN_TARGET", value: "repo/app with spaces/"},
+		{key: "PLUGIN_SPEC_VARS", value: "a=b;#c=d"},
+		{key: "PLUGIN_PEM_FILE_CONTENTS", value: "line 1\nline 2 \"quoted\" \\ #not a comment"},
+		{key: "PLUGIN_FLAT", value: ""},
+		{key: "PLUGIN_EXCLUSIONS", value: "*.tmp#not a comment"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Want variables\n%q\ngot\n%q", want, got)
+	}
+}
+
+func TestParseEnvFileErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+	}{
+		{name: "missing equals", in: "PLUGIN_URL"},
+		{name: "missing key", in: "=value"},
+		{name: "key with spaces", in: "PLUGIN URL=value"},
+		{name: "unterminated single quote", in: "PLUGIN_URL='value"},
+		{name: "unterminated double quote", in: `PLUGIN_URL="value`},
+		{name: "trailing text", in: `PLUGIN_URL="value" extra`},
+	}
+	for _, test := range tests {
+		if _, err := parseEnvFile([]byte(test.in)); err == nil {
+			t.Errorf("%s: expect error", test.name)
+		}
+	}
+}
+
+func TestLoadEnvFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(path, []byte("PLUGIN_TEST_SOURCE=dist/*.zip\nPLUGIN_TEST_TARGET=repo/app/\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PLUGIN_TEST_SOURCE", "")
+	os.Unsetenv("PLUGIN_TEST_SOURCE")
+	t.Setenv("PLUGIN_TEST_TARGET", "repo/override/")
+
+	if err := LoadEnvFile(path); err != nil {
+		t.Fatal(err)
+	}
+	if got := os.Getenv("PLUGIN_TEST_SOURCE"); got != "dist/*.zip" {
+		t.Errorf("Want the source loaded from the env file, got %q", got)
+	}
+	if got := os.Getenv("PLUGIN_TEST_TARGET"); got != "repo/override/" {
+		t.Errorf("Want the environment to take precedence, got %q", got)
+	}
+	if err := LoadEnvFile(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("Expect error for a missing env file")
+	}
+}
@@ -0,0 +1,79 @@
+// Copyright 2020 the Drone Authors. All rights reserved.
+// Use of this source code is governed by the Blue Oak Model License
+// that can be found in the LICENSE file.
+
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// searchResult is a single artifact printed by jfrog rt s.
+type searchResult struct {
+	Path string `json:"path"`
+}
+
+// abortOnExisting returns true if the upload fails when artifacts
+// already exist at the target.
+func abortOnExisting(args Args) bool {
+	return isUpload(args) && parseBoolOrDefault(false, args.AbortOnExisting)
+}
+
+// existingCommand returns the jfrog rt s arguments used to search
+// for artifacts at the upload target. A target ending with a slash
+// is a folder, so any artifact in the folder matches. The search
+// runs before the server is configured, so it always passes the
+// url and credentials as flags.
+func existingCommand(args Args) ([]string, error) {
+	search := args
+	search.Command = commandSearch
+	search.ServerID = ""
+	search.Source = ""
+	search.Exclusions = ""
+	search.Recursive = ""
+	if strings.HasSuffix(args.Target, "/") {
+		search.Target = args.Target + "*"
+	}
+	return searchCommand(search)
+}
+
+// checkExisting searches the upload target and returns an error if
+// any artifact already exists, so that no artifact is overwritten.
+func checkExisting(ctx context.Context, args Args, bin string) error {
+	cmdArgs, err := existingCommand(args)
+	if err != nil {
+		return err
+	}
+	var output bytes.Buffer
+	if err := run(ctx, args, bin, cmdArgs, &output, os.Stderr); err != nil {
+		return fmt.Errorf("error searching for existing artifacts: %s", err)
+	}
+	results, err := parseSearch(output.Bytes())
+	if err != nil {
+		return err
+	}
+	if len(results) > 0 {
+		return fmt.Errorf("%d artifacts already exist at %s, including %s, aborting the upload", len(results), args.Target, results[0].Path)
+	}
+	return nil
+}
+
+// parseSearch parses the json search results printed by jfrog rt s.
+// The cli writes its logs to stderr, so the output only holds the
+// results.
+func parseSearch(output []byte) ([]searchResult, error) {
+	output = bytes.TrimSpace(output)
+	if len(output) == 0 {
+		return nil, fmt.Errorf("no search results found in the command output")
+	}
+	var results []searchResult
+	if err := json.Unmarshal(output, &results); err != nil {
+		return nil, fmt.Errorf("error parsing search results: %s", err)
+	}
+	return results, nil
+}
@@ -0,0 +1,105 @@
+// Copyright 2020 the Drone Authors. All rights reserved.
+// Use of this source code is governed by the Blue Oak Model License
+// that can be found in the LICENSE file.
+
+package plugin
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestExistingCommand(t *testing.T) {
+	args := Args{
+		URL:        "https://artifactory.example.com",
+		APIKey:     "key",
+		ServerID:   "artifactory",
+		Source:     "dist/*.zip",
+		Target:     "repo/app/1.0/",
+		Exclusions: "*.tmp",
+		Recursive:  "false",
+	}
+	cmdArgs, err := existingCommand(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `rt s --url=https://artifactory.example.com --apikey=key repo/app/1.0/*`
+	if got := strings.Join(cmdArgs, " "); got != want {
+		t.Errorf("Want command\n%s\ngot\n%s", want, got)
+	}
+
+	args.Target = "repo/app/1.0/app.zip"
+	cmdArgs, err = existingCommand(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cmdArgs[len(cmdArgs)-1]; got != "repo/app/1.0/app.zip" {
+		t.Errorf("Want the target file searched, got %q", got)
+	}
+}
+
+func TestExecAbortOnExisting(t *testing.T) {
+	tests := []struct {
+		name    string
+		results string
+		calls   int
+		err     bool
+	}{
+		{
+			name:    "existing",
+			results: `[{"path": "repo/app/1.0/app.zip", "type": "file", "size": 1024}]`,
+			calls:   1,
+			err:     true,
+		},
+		{
+			name:    "empty",
+			results: "[]\n",
+			calls:   2,
+		},
+	}
+	for _, test := range tests {
+		runner := useFakeRunner(t, func(call fakeCall) error {
+			if call.args[1] == "s" {
+				_, err := io.WriteString(call.stdout, test.results)
+				return err
+			}
+			return nil
+		})
+		args := Args{
+			URL:             "https://artifactory.example.com",
+			APIKey:          "key",
+			Source:          "dist/app.zip",
+			Target:          "repo/app/1.0/",
+			AbortOnExisting: "true",
+		}
+		err := Exec(context.Background(), args)
+		if test.err {
+			if err == nil || !strings.Contains(err.Error(), "already exist") {
+				t.Errorf("%s: want error for existing artifacts, got %v", test.name, err)
+			}
+		} else if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.name, err)
+		}
+		if len(runner.calls) != test.calls {
+			t.Errorf("%s: want %d commands, got %d", test.name, test.calls, len(runner.calls))
+			continue
+		}
+		if runner.calls[0].args[1] != "s" {
+			t.Errorf("%s: want the search before the upload, got %q", test.name, runner.calls[0].args)
+		}
+		if test.calls > 1 && runner.calls[1].args[1] != "u" {
+			t.Errorf("%s: want the upload after the search, got %q", test.name, runner.calls[1].args)
+		}
+	}
+}
+
+func TestParseSearchErrors(t *testing.T) {
+	if _, err := parseSearch([]byte("\n")); err == nil {
+		t.Error("Expect error without search results")
+	}
+	if _, err := parseSearch([]byte(`[{"path": `)); err == nil {
+		t.Error("Expect error for invalid search results")
+	}
+}
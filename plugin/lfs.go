@@ -0,0 +1,30 @@
+// Copyright 2020 the Drone Authors. All rights reserved.
+// Use of this source code is governed by the Blue Oak Model License
+// that can be found in the LICENSE file.
+
+package plugin
+
+import "fmt"
+
+// lfsCleanCommand returns the jfrog rt glc arguments used to delete
+// the git lfs files that are no longer referenced by the git
+// repository. The repository path defaults to the working
+// directory. The deletion needs to be confirmed, see confirmArgs.
+func lfsCleanCommand(args Args) ([]string, error) {
+	cmdArgs, err := baseCommand(args, "rt", "glc")
+	if err != nil {
+		return nil, err
+	}
+	path, err := gitPath(args)
+	if err != nil {
+		return nil, err
+	}
+	if args.Refs != "" {
+		cmdArgs = append(cmdArgs, fmt.Sprintf("--refs=%s", args.Refs))
+	}
+	if args.TargetRepo != "" {
+		cmdArgs = append(cmdArgs, fmt.Sprintf("--repo=%s", args.TargetRepo))
+	}
+	cmdArgs = append(cmdArgs, confirmArgs(args, "Git lfs clean is not confirmed, listing unreferenced files only. Set quiet to true to delete them.")...)
+	return append(cmdArgs, path), nil
+}
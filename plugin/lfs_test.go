@@ -0,0 +1,68 @@
+// Copyright 2020 the Drone Authors. All rights reserved.
+// Use of this source code is governed by the Blue Oak Model License
+// that can be found in the LICENSE file.
+
+package plugin
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestLFSCleanCommand(t *testing.T) {
+	tests := []struct {
+		name string
+		args Args
+		want string
+	}{
+		{
+			name: "unconfirmed",
+			args: Args{GitPath: "/drone/src"},
+			want: `rt glc --url=https://artifactory.example.com --apikey=key --dry-run /drone/src`,
+		},
+		{
+			name: "confirmed",
+			args: Args{GitPath: "/drone/src", Quiet: "true", Refs: "refs/heads/*,refs/tags/*", TargetRepo: "lfs-local"},
+			want: `rt glc --url=https://artifactory.example.com --apikey=key --refs=refs/heads/*,refs/tags/* --repo=lfs-local --quiet /drone/src`,
+		},
+		{
+			name: "confirmed dry run",
+			args: Args{GitPath: "/drone/src", Quiet: "true", DryRun: "true"},
+			want: `rt glc --url=https://artifactory.example.com --apikey=key --dry-run /drone/src`,
+		},
+	}
+	for _, test := range tests {
+		args := test.args
+		args.Command = "git-lfs-clean"
+		args.URL = "https://artifactory.example.com"
+		args.APIKey = "key"
+		cmdArgs, err := buildCommand(args)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.name, err)
+			continue
+		}
+		if got := strings.Join(cmdArgs, " "); got != test.want {
+			t.Errorf("%s: want command\n%s\ngot\n%s", test.name, test.want, got)
+		}
+	}
+}
+
+func TestLFSCleanCommandDefaultPath(t *testing.T) {
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	args := Args{
+		Command: "git-lfs-clean",
+		URL:     "https://artifactory.example.com",
+		APIKey:  "key",
+	}
+	cmdArgs, err := buildCommand(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := cmdArgs[len(cmdArgs)-1]; got != wd {
+		t.Errorf("Want the repository path to default to %q, got %q", wd, got)
+	}
+}
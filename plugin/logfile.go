@@ -0,0 +1,34 @@
+// Copyright 2020 the Drone Authors. All rights reserved.
+// Use of this source code is governed by the Blue Oak Model License
+// that can be found in the LICENSE file.
+
+package plugin
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// createLogFile creates the log file and any missing parent
+// directories, truncating the output of an earlier run.
+func createLogFile(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("error creating log folder: %s", err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("error creating log file: %s", err)
+	}
+	return f.Close()
+}
+
+// openLogFile opens the log file to append the output of a
+// command.
+func openLogFile(path string) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("error opening log file: %s", err)
+	}
+	return f, nil
+}
@@ -0,0 +1,76 @@
+// Copyright 2020 the Drone Authors. All rights reserved.
+// Use of this source code is governed by the Blue Oak Model License
+// that can be found in the LICENSE file.
+
+package plugin
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExecLogFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "logs", "jfrog.log")
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("stale output\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	useFakeRunner(t, func(call fakeCall) error {
+		if _, err := io.WriteString(call.stdout, "uploaded "+call.args[len(call.args)-2]+"\n"); err != nil {
+			return err
+		}
+		_, err := io.WriteString(call.stderr, "[Info] done\n")
+		return err
+	})
+
+	args := Args{
+		URL:     "https://artifactory.example.com",
+		APIKey:  "key",
+		Source:  "dist/app.zip\ndocs/app.pdf",
+		Target:  "repo/app/",
+		LogFile: path,
+	}
+	if err := Exec(context.Background(), args); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "uploaded dist/app.zip\n[Info] done\nuploaded docs/app.pdf\n[Info] done\n"
+	if string(got) != want {
+		t.Errorf("Want log file %q, got %q", want, got)
+	}
+}
+
+func TestExecLogFileParents(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a", "b", "jfrog.log")
+	useFakeRunner(t, func(call fakeCall) error {
+		_, err := io.WriteString(call.stdout, "output")
+		return err
+	})
+
+	args := Args{
+		URL:     "https://artifactory.example.com",
+		APIKey:  "key",
+		Command: "search",
+		Target:  "repo/app/*.zip",
+		LogFile: path,
+	}
+	if err := Exec(context.Background(), args); err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(got), "output") {
+		t.Errorf("Want the command output in the log file, got %q", got)
+	}
+}
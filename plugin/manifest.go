@@ -0,0 +1,52 @@
+// Copyright 2020 the Drone Authors. All rights reserved.
+// Use of this source code is governed by the Blue Oak Model License
+// that can be found in the LICENSE file.
+
+package plugin
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// manifestSources returns the upload sources listed in the source
+// manifest. A manifest of "-" is read from the standard input.
+func manifestSources(path string) ([]string, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading source manifest: %s", err)
+	}
+	sources, err := parseManifest(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(sources) == 0 {
+		return nil, fmt.Errorf("source manifest %q has no sources", path)
+	}
+	return sources, nil
+}
+
+// parseManifest parses a manifest with one source pattern per line.
+// Blank lines and lines starting with # are ignored.
+func parseManifest(data []byte) ([]string, error) {
+	var sources []string
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		sources = append(sources, line)
+	}
+	return sources, scanner.Err()
+}
@@ -0,0 +1,93 @@
+// Copyright 2020 the Drone Authors. All rights reserved.
+// Use of this source code is governed by the Blue Oak Model License
+// that can be found in the LICENSE file.
+
+package plugin
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseManifest(t *testing.T) {
+	data := []byte("# release artifacts\ndist/*.tar.gz\n\n  docs/*.pdf  \n\t# docs only\r\nREADME.md\r\n")
+	got, err := parseManifest(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"dist/*.tar.gz", "docs/*.pdf", "README.md"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Want sources %q, got %q", want, got)
+	}
+}
+
+func TestBuildCommandsSourceManifest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "manifest.txt")
+	if err := os.WriteFile(path, []byte("dist/*.tar.gz\n# skipped\n\ndocs/*.pdf\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	args := Args{
+		URL:            "https://artifactory.example.com",
+		APIKey:         "key",
+		SourceManifest: path,
+		Target:         "repo/app/",
+	}
+	cmds, err := buildCommands(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, cmdArgs := range cmds {
+		got = append(got, strings.Join(cmdArgs, " "))
+	}
+	want := []string{
+		"rt u --url=https://artifactory.example.com --apikey=key dist/*.tar.gz repo/app/",
+		"rt u --url=https://artifactory.example.com --apikey=key docs/*.pdf repo/app/",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Want commands\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+}
+
+func TestBuildCommandsSourceManifestErrors(t *testing.T) {
+	empty := filepath.Join(t.TempDir(), "manifest.txt")
+	if err := os.WriteFile(empty, []byte("# nothing to upload\n\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		args Args
+		want string
+	}{
+		{
+			name: "missing manifest",
+			args: Args{SourceManifest: filepath.Join(t.TempDir(), "missing.txt"), Target: "repo/app/"},
+			want: "error reading source manifest",
+		},
+		{
+			name: "empty manifest",
+			args: Args{SourceManifest: empty, Target: "repo/app/"},
+			want: "has no sources",
+		},
+		{
+			name: "download",
+			args: Args{Command: "download", SourceManifest: empty, Source: "repo/app/"},
+			want: "source manifest can only be set for upload",
+		},
+	}
+	for _, test := range tests {
+		test.args.URL = "https://artifactory.example.com"
+		test.args.APIKey = "key"
+		_, err := buildCommands(test.args)
+		if err == nil {
+			t.Errorf("%s: expect error", test.name)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: want error containing %q, got %q", test.name, test.want, err)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+// Copyright 2020 the Drone Authors. All rights reserved.
+// Use of this source code is governed by the Blue Oak Model License
+// that can be found in the LICENSE file.
+
+package plugin
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// npmPublishCommand returns the jfrog npm publish arguments used to
+// pack and publish the npm package in the build directory. The
+// server and repository are read from the npm configuration of the
+// project, so no server flags are needed. The command targets the
+// jfrog cli 2.36.1 installed by the docker images, which replaced
+// rt npm-publish with npm publish.
+func npmPublishCommand(args Args) ([]string, error) {
+	cmdArgs := []string{"npm", "publish"}
+	cmdArgs = append(cmdArgs, buildArgs(args)...)
+	cmdArgs = append(cmdArgs, projectArgs(args)...)
+	return cmdArgs, nil
+}
+
+// usesProjectConfig returns true if the command reads the server id
+// from the project configuration in .jfrog/projects, written by the
+// jfrog npm-config, mvn-config, go-config and pip-config commands.
+// The server id needs to match the server id of the run, since the
+// server is configured in the config home of the run.
+func usesProjectConfig(args Args) bool {
+	switch args.Command {
+	case commandNpmPublish, commandMaven, commandGoPublish, commandPipInstall:
+		return true
+	default:
+		return false
+	}
+}
+
+// mavenCommand returns the jfrog mvn arguments used to run the
+// maven goals in the build directory. The deployment repositories
+// are read from the maven configuration of the project, which the
+// build config file is copied to when set. The command targets the
+// jfrog cli 2.36.1 installed by the docker images, which replaced
+// rt mvn and its config file argument with mvn.
+func mavenCommand(args Args) ([]string, error) {
+	if args.MavenGoals == "" {
+		return nil, fmt.Errorf("maven goals needs to be set")
+	}
+	goals, err := splitFields(args.MavenGoals)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing maven goals: %s", err)
+	}
+	cmdArgs := append([]string{"mvn"}, goals...)
+	cmdArgs = append(cmdArgs, buildArgs(args)...)
+	cmdArgs = append(cmdArgs, projectArgs(args)...)
+	return cmdArgs, nil
+}
+
+// mavenConfigPath returns the path of the maven configuration of the
+// project in dir, which jfrog mvn reads the repositories from.
+func mavenConfigPath(dir string) string {
+	return filepath.Join(dir, ".jfrog", "projects", "maven.yaml")
+}
+
+// writeMavenConfig copies the build config file to the maven
+// configuration of the project, since jfrog cli 2 has no flag for
+// the config file. An existing maven configuration is never
+// replaced. It returns the created path, which is the topmost
+// directory created, if any.
+func writeMavenConfig(args Args) ([]string, error) {
+	if args.Command != commandMaven || args.BuildConfig == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(resolvePath(args, args.BuildConfig))
+	if err != nil {
+		return nil, fmt.Errorf("error reading build config: %s", err)
+	}
+	path := mavenConfigPath(commandDir(args))
+	if _, err := os.Stat(path); err == nil {
+		return nil, fmt.Errorf("either build config or the maven config %q needs to be set, not both", path)
+	}
+	created := path
+	for _, dir := range []string{filepath.Dir(path), filepath.Dir(filepath.Dir(path))} {
+		if _, err := os.Stat(dir); os.IsNotExist(err) {
+			created = dir
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("error creating maven config folder: %s", err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return []string{created}, fmt.Errorf("error writing maven config: %s", err)
+	}
+	return []string{created}, nil
+}
+
+// goPublishCommand returns the jfrog gp arguments used to publish
+// the go module in the build directory. The repository is read from
+// the go configuration of the project. The command targets the jfrog
+// cli 2.36.1 installed by the docker images, which replaced
+// rt go-publish with gp.
+func goPublishCommand(args Args) ([]string, error) {
+	if args.ModuleVersion == "" {
+		return nil, fmt.Errorf("module version needs to be set")
+	}
+	cmdArgs := []string{"gp"}
+	cmdArgs = append(cmdArgs, buildArgs(args)...)
+	cmdArgs = append(cmdArgs, projectArgs(args)...)
+	return append(cmdArgs, args.ModuleVersion), nil
+}
+
+// pipInstallCommand returns the jfrog pip install arguments used to
+// install the requirements in the build directory. The repository
+// is read from the pip configuration of the project. The command
+// targets the jfrog cli 2.36.1 installed by the docker images, which
+// replaced rt pip-install with pip install.
+func pipInstallCommand(args Args) ([]string, error) {
+	if args.Requirements == "" {
+		return nil, fmt.Errorf("requirements needs to be set")
+	}
+	cmdArgs := []string{"pip", "install"}
+	cmdArgs = append(cmdArgs, buildArgs(args)...)
+	cmdArgs = append(cmdArgs, projectArgs(args)...)
+	return append(cmdArgs, "-r", args.Requirements), nil
+}
+
+// pipPublishCommand returns the jfrog rt u arguments used to upload
+// the python distributions to the target pypi repository. The
+// distribution path defaults to the dist folder written by the
+// python build tools.
+func pipPublishCommand(args Args) ([]string, error) {
+	cmdArgs, err := baseCommand(args, "rt", "u")
+	if err != nil {
+		return nil, err
+	}
+	if args.TargetRepo == "" {
+		return nil, fmt.Errorf("target repo needs to be set")
+	}
+	path := args.DistPath
+	if path == "" {
+		path = "dist/*"
+	}
+	cmdArgs = append(cmdArgs, "--flat=true")
+	cmdArgs = append(cmdArgs, buildArgs(args)...)
+	cmdArgs = append(cmdArgs, projectArgs(args)...)
+	return append(cmdArgs, path, args.TargetRepo+"/"), nil
+}
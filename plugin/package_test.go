@@ -0,0 +1,367 @@
+// Copyright 2020 the Drone Authors. All rights reserved.
+// Use of this source code is governed by the Blue Oak Model License
+// that can be found in the LICENSE file.
+
+package plugin
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNpmPublishCommand(t *testing.T) {
+	args := Args{
+		Command:     "npm-publish",
+		URL:         "https://artifactory.example.com",
+		APIKey:      "key",
+		BuildName:   "app",
+		BuildNumber: "42",
+	}
+	cmdArgs, err := buildCommand(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `npm publish --build-name=app --build-number=42`
+	if got := strings.Join(cmdArgs, " "); got != want {
+		t.Errorf("Want command\n%s\ngot\n%s", want, got)
+	}
+}
+
+func TestExecNpmPublishBuildDir(t *testing.T) {
+	runner := useFakeRunner(t, nil)
+
+	args := Args{
+		Command:  "npm-publish",
+		URL:      "https://artifactory.example.com",
+		APIKey:   "key",
+		BuildDir: "web/app",
+	}
+	if err := Exec(context.Background(), args); err != nil {
+		t.Fatal(err)
+	}
+	if len(runner.calls) != 2 {
+		t.Fatalf("Want the config and publish commands, got %d", len(runner.calls))
+	}
+	if got := runner.calls[1].dir; got != "web/app" {
+		t.Errorf("Want the command to run in the build directory, got %q", got)
+	}
+}
+
+func TestExecPackageConfiguresServer(t *testing.T) {
+	tests := []struct {
+		name string
+		args Args
+		want []string
+	}{
+		{name: "npm", args: Args{Command: "npm-publish"}, want: []string{"npm", "publish"}},
+		{name: "maven", args: Args{Command: "maven", MavenGoals: "clean install"}, want: []string{"mvn", "clean", "install"}},
+		{name: "go", args: Args{Command: "go-publish", ModuleVersion: "v1.2.0"}, want: []string{"gp", "v1.2.0"}},
+		{name: "pip", args: Args{Command: "pip-install", Requirements: "requirements.txt"}, want: []string{"pip", "install", "-r", "requirements.txt"}},
+	}
+	for _, test := range tests {
+		runner := useFakeRunner(t, nil)
+		args := test.args
+		args.URL = "https://artifactory.example.com"
+		args.APIKey = "key"
+		args.ServerID = "artifactory"
+		if err := Exec(context.Background(), args); err != nil {
+			t.Errorf("%s: unexpected error: %s", test.name, err)
+			continue
+		}
+		if len(runner.calls) != 2 {
+			t.Errorf("%s: want the config and package commands, got %d", test.name, len(runner.calls))
+			continue
+		}
+		want := []string{"config", "add", "artifactory", "--artifactory-url=https://artifactory.example.com", "--apikey=key", "--interactive=false", "--overwrite=true"}
+		if got := runner.calls[0].args; !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: want the server configured first %q, got %q", test.name, want, got)
+		}
+		if got := runner.calls[1].args; !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: want command %q, got %q", test.name, test.want, got)
+		}
+	}
+
+	args := Args{Command: "npm-publish", URL: "https://artifactory.example.com"}
+	if err := Exec(context.Background(), args); err == nil {
+		t.Error("Expect error when the credentials are missing")
+	}
+}
+
+func TestMavenCommand(t *testing.T) {
+	args := Args{
+		Command:     "maven",
+		URL:         "https://artifactory.example.com",
+		APIKey:      "key",
+		MavenGoals:  "clean install -DskipTests",
+		BuildConfig: "configuration.yml",
+		BuildName:   "app",
+		BuildNumber: "42",
+	}
+	cmdArgs, err := buildCommand(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"mvn", "clean", "install", "-DskipTests", "--build-name=app", "--build-number=42"}
+	if !reflect.DeepEqual(cmdArgs, want) {
+		t.Errorf("Want command %q, got %q", want, cmdArgs)
+	}
+}
+
+func TestExecMavenBuildConfig(t *testing.T) {
+	dir := t.TempDir()
+	config := "version: 1\ntype: maven\n"
+	if err := os.WriteFile(filepath.Join(dir, "configuration.yml"), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	path := mavenConfigPath(dir)
+	useFakeRunner(t, func(call fakeCall) error {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Errorf("Want the build config copied to the maven config: %s", err)
+		} else if string(data) != config {
+			t.Errorf("Want maven config %q, got %q", config, data)
+		}
+		return nil
+	})
+
+	args := Args{
+		Command:     "maven",
+		URL:         "https://artifactory.example.com",
+		APIKey:      "key",
+		MavenGoals:  "clean install",
+		BuildConfig: "configuration.yml",
+		WorkingDir:  dir,
+	}
+	if err := Exec(context.Background(), args); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, ".jfrog")); !os.IsNotExist(err) {
+		t.Error("Want the maven config removed after running")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := Exec(context.Background(), args); err == nil {
+		t.Error("Expect error when the project has a maven config")
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Errorf("Want the existing maven config kept: %s", err)
+	}
+}
+
+func TestMavenCommandErrors(t *testing.T) {
+	args := Args{
+		Command: "maven",
+		URL:     "https://artifactory.example.com",
+		APIKey:  "key",
+	}
+	if _, err := buildCommand(args); err == nil {
+		t.Error("Expect error when maven goals are missing")
+	}
+}
+
+func TestGoPublishCommand(t *testing.T) {
+	args := Args{
+		Command:       "go-publish",
+		URL:           "https://artifactory.example.com",
+		APIKey:        "key",
+		ModuleVersion: "v1.2.0",
+		BuildName:     "app",
+		BuildNumber:   "42",
+	}
+	cmdArgs, err := buildCommand(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `gp --build-name=app --build-number=42 v1.2.0`
+	if got := strings.Join(cmdArgs, " "); got != want {
+		t.Errorf("Want command\n%s\ngot\n%s", want, got)
+	}
+	args.BuildDir = "modules/app"
+	if got := commandDir(args); got != "modules/app" {
+		t.Errorf("Want go-publish to run in the build directory, got %q", got)
+	}
+}
+
+func TestGoPublishCommandErrors(t *testing.T) {
+	args := Args{
+		Command: "go-publish",
+		URL:     "https://artifactory.example.com",
+		APIKey:  "key",
+	}
+	if _, err := buildCommand(args); err == nil || err.Error() != "module version needs to be set" {
+		t.Errorf("Expect error when module version is missing, got %v", err)
+	}
+}
+
+func TestPipInstallCommand(t *testing.T) {
+	args := Args{
+		Command:      "pip-install",
+		URL:          "https://artifactory.example.com",
+		APIKey:       "key",
+		Requirements: "requirements.txt",
+		BuildName:    "app",
+		BuildNumber:  "42",
+	}
+	cmdArgs, err := buildCommand(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `pip install --build-name=app --build-number=42 -r requirements.txt`
+	if got := strings.Join(cmdArgs, " "); got != want {
+		t.Errorf("Want command\n%s\ngot\n%s", want, got)
+	}
+	args.Requirements = ""
+	if _, err := buildCommand(args); err == nil {
+		t.Error("Expect error when requirements are missing")
+	}
+}
+
+func TestPipPublishCommand(t *testing.T) {
+	args := Args{
+		Command:     "pip-publish",
+		URL:         "https://artifactory.example.com",
+		APIKey:      "key",
+		DistPath:    "dist/*.whl",
+		TargetRepo:  "pypi-local",
+		BuildName:   "app",
+		BuildNumber: "42",
+		BuildDir:    "python/app",
+	}
+	cmdArgs, err := buildCommand(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `rt u --url=https://artifactory.example.com --apikey=key --flat=true --build-name=app --build-number=42 dist/*.whl pypi-local/`
+	if got := strings.Join(cmdArgs, " "); got != want {
+		t.Errorf("Want command\n%s\ngot\n%s", want, got)
+	}
+	if got := commandDir(args); got != "python/app" {
+		t.Errorf("Want pip-publish to run in the build directory, got %q", got)
+	}
+	args.TargetRepo = ""
+	if _, err := buildCommand(args); err == nil {
+		t.Error("Expect error when target repo is missing")
+	}
+}
+
+func TestCommandDir(t *testing.T) {
+	tests := []struct {
+		args Args
+		want string
+	}{
+		{args: Args{BuildDir: "web/app"}, want: ""},
+		{args: Args{WorkingDir: "/drone/src"}, want: "/drone/src"},
+		{args: Args{WorkingDir: "/drone/src", BuildDir: "web/app"}, want: "/drone/src"},
+		{args: Args{Command: "npm-publish", BuildDir: "web/app"}, want: "web/app"},
+		{args: Args{Command: "npm-publish", WorkingDir: "/drone/src"}, want: "/drone/src"},
+		{args: Args{Command: "npm-publish", WorkingDir: "/drone/src", BuildDir: "web/app"}, want: filepath.Join("/drone/src", "web/app")},
+		{args: Args{Command: "npm-publish", WorkingDir: "/drone/src", BuildDir: "/web/app"}, want: "/web/app"},
+	}
+	for _, test := range tests {
+		if got := commandDir(test.args); got != test.want {
+			t.Errorf("Want directory %q for command %q, got %q", test.want, test.args.Command, got)
+		}
+	}
+}
+
+func TestResolvePath(t *testing.T) {
+	tests := []struct {
+		args Args
+		path string
+		want string
+	}{
+		{args: Args{}, path: "spec.json", want: "spec.json"},
+		{args: Args{WorkingDir: "/drone/src"}, path: "spec.json", want: filepath.Join("/drone/src", "spec.json")},
+		{args: Args{WorkingDir: "/drone/src"}, path: "/tmp/spec.json", want: "/tmp/spec.json"},
+		{args: Args{Command: "npm-publish", WorkingDir: "/drone/src", BuildDir: "web"}, path: "rules.json", want: filepath.Join("/drone/src", "web", "rules.json")},
+	}
+	for _, test := range tests {
+		if got := resolvePath(test.args, test.path); got != test.want {
+			t.Errorf("Want path %q for %q, got %q", test.want, test.path, got)
+		}
+	}
+}
+
+func TestBuildCommandsWorkingDirPaths(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, data := range map[string]string{
+		"spec.json":  `{"files": []}`,
+		"vars.txt":   "key=value\n",
+		"query.aql":  `items.find()`,
+		"rules.json": `{"distribution_rules": []}`,
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	tests := []struct {
+		name    string
+		args    Args
+		want    string
+		checked bool
+	}{
+		{name: "collect git", args: Args{Command: "collect-git", BuildName: "app", BuildNumber: "42"}, want: dir, checked: true},
+		{name: "git path", args: Args{Command: "collect-git", BuildName: "app", BuildNumber: "42", GitPath: "."}, want: ".", checked: true},
+		{name: "git lfs clean", args: Args{Command: "git-lfs-clean"}, want: dir},
+		{name: "spec", args: Args{Spec: "spec.json", SpecVarsFile: "vars.txt"}, want: "--spec-vars=key=value", checked: true},
+		{name: "aql file", args: Args{Command: "aql", AQLFile: "query.aql"}, want: "@query.aql", checked: true},
+		{name: "dist rules", args: Args{Command: "release-bundle-distribute", BundleName: "app", BundleVersion: "1.0.0", DistRules: "rules.json"}, want: "--dist-rules=rules.json", checked: true},
+	}
+	for _, test := range tests {
+		args := test.args
+		args.URL = "https://artifactory.example.com"
+		args.APIKey = "key"
+		if _, err := buildCommands(args); test.checked && err == nil {
+			t.Errorf("%s: expect error for paths relative to the plugin directory", test.name)
+		}
+		args.WorkingDir = dir
+		cmds, err := buildCommands(args)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.name, err)
+			continue
+		}
+		if cmdArgs := cmds[len(cmds)-1]; !contains(cmdArgs, test.want) {
+			t.Errorf("%s: want %q in command %q", test.name, test.want, cmdArgs)
+		}
+	}
+}
+
+func TestExecWorkingDir(t *testing.T) {
+	dir := t.TempDir()
+	runner := useFakeRunner(t, nil)
+
+	args := Args{
+		URL:        "https://artifactory.example.com",
+		APIKey:     "key",
+		Source:     "dist/app.zip",
+		Target:     "repo/app/",
+		WorkingDir: dir,
+	}
+	if err := Exec(context.Background(), args); err != nil {
+		t.Fatal(err)
+	}
+	if len(runner.calls) != 1 || runner.calls[0].dir != dir {
+		t.Errorf("Want the command to run in %q, got %+v", dir, runner.calls)
+	}
+
+	runner.calls = nil
+	args.WorkingDir = filepath.Join(dir, "missing")
+	if err := Exec(context.Background(), args); err == nil {
+		t.Error("Expect error when the working dir does not exist")
+	}
+	if len(runner.calls) != 0 {
+		t.Errorf("Want no command to run, got %d", len(runner.calls))
+	}
+}
@@ -5,14 +5,21 @@
 package plugin
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"fmt"
+	"io"
+	"math/rand"
+	"net/url"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strconv"
 	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
 )
 
 // Args provides plugin execution arguments.
@@ -23,141 +30,1432 @@ type Args struct {
 	Level string `envconfig:"PLUGIN_LOG_LEVEL"`
 
 	// TODO replace or remove
-	Username        string `envconfig:"PLUGIN_USERNAME"`
-	Password        string `envconfig:"PLUGIN_PASSWORD"`
-	APIKey          string `envconfig:"PLUGIN_API_KEY"`
-	AccessToken     string `envconfig:"PLUGIN_ACCESS_TOKEN"`
-	URL             string `envconfig:"PLUGIN_URL"`
-	Source          string `envconfig:"PLUGIN_SOURCE"`
-	Target          string `envconfig:"PLUGIN_TARGET"`
-	Retries         int    `envconfig:"PLUGIN_RETRIES"`
-	Flat            string `envconfig:"PLUGIN_FLAT"`
-	Spec            string `envconfig:"PLUGIN_SPEC"`
-	Threads         int    `envconfig:"PLUGIN_THREADS"`
-	SpecVars        string `envconfig:"PLUGIN_SPEC_VARS"`
-	Insecure        string `envconfig:"PLUGIN_INSECURE"`
-	PEMFileContents string `envconfig:"PLUGIN_PEM_FILE_CONTENTS"`
-	PEMFilePath     string `envconfig:"PLUGIN_PEM_FILE_PATH"`
+	Username             string `envconfig:"PLUGIN_USERNAME"`
+	Password             string `envconfig:"PLUGIN_PASSWORD"`
+	APIKey               string `envconfig:"PLUGIN_API_KEY"`
+	AccessToken          string `envconfig:"PLUGIN_ACCESS_TOKEN"`
+	IdentityToken        string `envconfig:"PLUGIN_IDENTITY_TOKEN"`
+	URL                  string `envconfig:"PLUGIN_URL"`
+	PlatformURL          string `envconfig:"PLUGIN_PLATFORM_URL"`
+	ServerID             string `envconfig:"PLUGIN_SERVER_ID"`
+	Source               string `envconfig:"PLUGIN_SOURCE"`
+	SourceManifest       string `envconfig:"PLUGIN_SOURCE_MANIFEST"`
+	Target               string `envconfig:"PLUGIN_TARGET"`
+	RepoKey              string `envconfig:"PLUGIN_REPO"`
+	Path                 string `envconfig:"PLUGIN_PATH"`
+	Retries              int    `envconfig:"PLUGIN_RETRIES"`
+	RetryWaitTime        int    `envconfig:"PLUGIN_RETRY_WAIT_TIME"`
+	Flat                 string `envconfig:"PLUGIN_FLAT"`
+	Spec                 string `envconfig:"PLUGIN_SPEC"`
+	Threads              int    `envconfig:"PLUGIN_THREADS"`
+	SpecVars             string `envconfig:"PLUGIN_SPEC_VARS"`
+	Insecure             string `envconfig:"PLUGIN_INSECURE"`
+	PEMFileContents      string `envconfig:"PLUGIN_PEM_FILE_CONTENTS"`
+	PEMFilePath          string `envconfig:"PLUGIN_PEM_FILE_PATH"`
+	StartupDelay         string `envconfig:"PLUGIN_STARTUP_DELAY"`
+	Command              string `envconfig:"PLUGIN_COMMAND"`
+	Timeout              string `envconfig:"PLUGIN_TIMEOUT"`
+	Recursive            string `envconfig:"PLUGIN_RECURSIVE"`
+	DryRun               string `envconfig:"PLUGIN_DRY_RUN"`
+	ValidateOnly         string `envconfig:"PLUGIN_VALIDATE_ONLY"`
+	Quiet                string `envconfig:"PLUGIN_QUIET"`
+	Exclusions           string `envconfig:"PLUGIN_EXCLUSIONS"`
+	OutputFile           string `envconfig:"PLUGIN_OUTPUT_FILE"`
+	OutputFormat         string `envconfig:"PLUGIN_OUTPUT_FORMAT"`
+	LogFile              string `envconfig:"PLUGIN_LOG_FILE"`
+	TargetProps          string `envconfig:"PLUGIN_TARGET_PROPS"`
+	PropsMap             string `envconfig:"PLUGIN_PROPS_MAP"`
+	Props                string `envconfig:"PLUGIN_PROPS"`
+	PatternType          string `envconfig:"PLUGIN_PATTERN_TYPE"`
+	Archive              string `envconfig:"PLUGIN_ARCHIVE"`
+	Explode              string `envconfig:"PLUGIN_EXPLODE"`
+	ExplodeUpload        string `envconfig:"PLUGIN_EXPLODE_UPLOAD"`
+	SyncDeletes          string `envconfig:"PLUGIN_SYNC_DELETES"`
+	SyncDeletesThreshold int    `envconfig:"PLUGIN_SYNC_DELETES_THRESHOLD"`
+	DetailedSummary      string `envconfig:"PLUGIN_DETAILED_SUMMARY"`
+	VerifyChecksum       string `envconfig:"PLUGIN_VERIFY_CHECKSUM"`
+	FailNoOp             string `envconfig:"PLUGIN_FAIL_NO_OP"`
+	MinSplit             int    `envconfig:"PLUGIN_MIN_SPLIT"`
+	SplitCount           int    `envconfig:"PLUGIN_SPLIT_COUNT"`
+	ChunkSize            int    `envconfig:"PLUGIN_CHUNK_SIZE"`
+	Project              string `envconfig:"PLUGIN_PROJECT"`
+	JfrogBin             string `envconfig:"PLUGIN_JFROG_BIN"`
+	PluginRetries        int    `envconfig:"PLUGIN_PLUGIN_RETRIES"`
+	SpecVarsFile         string `envconfig:"PLUGIN_SPEC_VARS_FILE"`
+	Ping                 string `envconfig:"PLUGIN_PING"`
+	HTTPProxy            string `envconfig:"PLUGIN_HTTP_PROXY"`
+	HTTPSProxy           string `envconfig:"PLUGIN_HTTPS_PROXY"`
+	NoProxy              string `envconfig:"PLUGIN_NO_PROXY"`
+	ConfigHome           string `envconfig:"PLUGIN_CONFIG_HOME"`
+	PropKeys             string `envconfig:"PLUGIN_PROP_KEYS"`
+	BuildName            string `envconfig:"PLUGIN_BUILD_NAME"`
+	BuildNumber          string `envconfig:"PLUGIN_BUILD_NUMBER"`
+	BuildURL             string `envconfig:"PLUGIN_BUILD_URL"`
+	PublishBuildInfo     string `envconfig:"PLUGIN_PUBLISH_BUILD_INFO"`
+	AbortOnExisting      string `envconfig:"PLUGIN_ABORT_ON_EXISTING"`
+	EnvInclude           string `envconfig:"PLUGIN_ENV_INCLUDE"`
+	EnvExclude           string `envconfig:"PLUGIN_ENV_EXCLUDE"`
+	TargetRepo           string `envconfig:"PLUGIN_TARGET_REPO"`
+	PromoteStatus        string `envconfig:"PLUGIN_PROMOTE_STATUS"`
+	PromoteComment       string `envconfig:"PLUGIN_PROMOTE_COMMENT"`
+	PromoteCopy          string `envconfig:"PLUGIN_PROMOTE_COPY"`
+	GitPath              string `envconfig:"PLUGIN_GIT_PATH"`
+	MaxBuilds            int    `envconfig:"PLUGIN_MAX_BUILDS"`
+	MaxDays              int    `envconfig:"PLUGIN_MAX_DAYS"`
+	ExcludeBuilds        string `envconfig:"PLUGIN_EXCLUDE_BUILDS"`
+	AppendBuildName      string `envconfig:"PLUGIN_APPEND_BUILD_NAME"`
+	AppendBuildNumber    string `envconfig:"PLUGIN_APPEND_BUILD_NUMBER"`
+	ImageTag             string `envconfig:"PLUGIN_IMAGE_TAG"`
+	SourceRepo           string `envconfig:"PLUGIN_SOURCE_REPO"`
+	BuildDir             string `envconfig:"PLUGIN_BUILD_DIR"`
+	MavenGoals           string `envconfig:"PLUGIN_MAVEN_GOALS"`
+	BuildConfig          string `envconfig:"PLUGIN_BUILD_CONFIG"`
+	ModuleVersion        string `envconfig:"PLUGIN_MODULE_VERSION"`
+	Requirements         string `envconfig:"PLUGIN_REQUIREMENTS"`
+	DistPath             string `envconfig:"PLUGIN_DIST_PATH"`
+	CurlArgs             string `envconfig:"PLUGIN_CURL_ARGS"`
+	AQLQuery             string `envconfig:"PLUGIN_AQL"`
+	AQLFile              string `envconfig:"PLUGIN_AQL_FILE"`
+	BundleName           string `envconfig:"PLUGIN_BUNDLE_NAME"`
+	BundleVersion        string `envconfig:"PLUGIN_BUNDLE_VERSION"`
+	Sign                 string `envconfig:"PLUGIN_SIGN"`
+	GPGPassphrase        string `envconfig:"PLUGIN_GPG_PASSPHRASE"`
+	PassphraseFile       string `envconfig:"PLUGIN_PASSPHRASE_FILE"`
+	DistRules            string `envconfig:"PLUGIN_DIST_RULES"`
+	Site                 string `envconfig:"PLUGIN_SITE"`
+	City                 string `envconfig:"PLUGIN_CITY"`
+	CountryCodes         string `envconfig:"PLUGIN_COUNTRY_CODES"`
+	Sync                 string `envconfig:"PLUGIN_SYNC"`
+	DeleteFromDist       string `envconfig:"PLUGIN_DELETE_FROM_DIST"`
+	Watches              string `envconfig:"PLUGIN_WATCHES"`
+	ScanFail             string `envconfig:"PLUGIN_SCAN_FAIL"`
+	MaxViolations        int    `envconfig:"PLUGIN_MAX_VIOLATIONS"`
+	Refs                 string `envconfig:"PLUGIN_REFS"`
+	WorkingDir           string `envconfig:"PLUGIN_WORKING_DIR"`
+	Symlinks             string `envconfig:"PLUGIN_SYMLINKS"`
+	ValidateSymlinks     string `envconfig:"PLUGIN_VALIDATE_SYMLINKS"`
+	IncludeDirs          string `envconfig:"PLUGIN_INCLUDE_DIRS"`
+	Module               string `envconfig:"PLUGIN_MODULE"`
+	Deb                  string `envconfig:"PLUGIN_DEB"`
 }
 
+// Supported values for the plugin command.
+const (
+	commandUpload   = "upload"
+	commandDownload = "download"
+	commandCopy     = "copy"
+	commandMove     = "move"
+	commandDelete   = "delete"
+	commandSearch   = "search"
+	commandSetProps = "set-props"
+	commandDelProps = "delete-props"
+
+	commandPublishBuild = "publish-build"
+	commandCollectEnv   = "collect-env"
+	commandPromote      = "promote"
+	commandCollectGit   = "collect-git"
+	commandDiscard      = "discard-builds"
+	commandCollectDeps  = "collect-deps"
+	commandBuildAppend  = "build-append"
+
+	commandDockerPush = "docker-push"
+	commandDockerPull = "docker-pull"
+	commandNpmPublish = "npm-publish"
+	commandMaven      = "maven"
+	commandGoPublish  = "go-publish"
+	commandPipInstall = "pip-install"
+	commandPipPublish = "pip-publish"
+	commandCurl       = "curl"
+	commandAQL        = "aql"
+
+	commandBundleCreate = "release-bundle-create"
+	commandBundleSign   = "release-bundle-sign"
+	commandBundleDist   = "release-bundle-distribute"
+	commandBundleDelete = "release-bundle-delete"
+
+	commandScan      = "scan"
+	commandBuildScan = "build-scan"
+	commandLFSClean  = "git-lfs-clean"
+)
+
+// Supported values for the source pattern type.
+const (
+	patternWildcard = "wildcard"
+	patternRegexp   = "regexp"
+	patternAnt      = "ant"
+)
+
+// Output formats
+const (
+	formatText = "text"
+	formatJSON = "json"
+)
+
 // Exec executes the plugin.
 func Exec(ctx context.Context, args Args) error {
-	// write code here
-	if args.URL == "" {
-		return fmt.Errorf("url needs to be set")
+	_, err := ExecWithResult(ctx, args)
+	return err
+}
+
+// ExecResult provides the details of a plugin run.
+type ExecResult struct {
+	// Operation is the plugin command that ran.
+	Operation string
+	// FilesAffected is the number of artifacts transferred, when
+	// the detailed summary is enabled.
+	FilesAffected int
+	// Duration is the time taken by the run.
+	Duration time.Duration
+	// RawOutput is the output of the jfrog commands.
+	RawOutput string
+	// Summary is the merged detailed summary, or nil if the
+	// detailed summary is not enabled.
+	Summary *Summary
+}
+
+// ExecWithResult executes the plugin and returns the details of
+// the run. The result is returned with the error when any command
+// ran, so that a partial failure can be inspected.
+func ExecWithResult(ctx context.Context, args Args) (*ExecResult, error) {
+	start := time.Now()
+	args.Target = composeTarget(args)
+	if err := validate(args); err != nil {
+		return nil, err
 	}
 
-	cmdArgs := []string{getJfrogBin(), "rt", "u", fmt.Sprintf("--url %s", args.URL)}
-	if args.Retries != 0 {
-		cmdArgs = append(cmdArgs, fmt.Sprintf("--retries=%d", args.Retries))
+	warnCredentials(args)
+
+	// Normalize the url, so that paths appended to it have a single
+	// separator
+	args.URL = strings.TrimRight(args.URL, "/")
+
+	// Read the passphrase once, so that it is passed and masked
+	// like an inline passphrase. It is still passed to the jfrog cli
+	// as a flag, see passphraseArgs
+	if args.PassphraseFile != "" {
+		passphrase, err := readPassphraseFile(resolvePath(args, args.PassphraseFile))
+		if err != nil {
+			return nil, err
+		}
+		args.GPGPassphrase = passphrase
 	}
 
-	// Set authentication params
-	envPrefix := getEnvPrefix()
-	if args.Username != "" && args.Password != "" {
-		cmdArgs = append(cmdArgs, fmt.Sprintf("--user %sPLUGIN_USERNAME", envPrefix))
-		cmdArgs = append(cmdArgs, fmt.Sprintf("--password %sPLUGIN_PASSWORD", envPrefix))
-	} else if args.APIKey != "" {
-		cmdArgs = append(cmdArgs, fmt.Sprintf("--apikey %sPLUGIN_API_KEY", envPrefix))
-	} else if args.AccessToken != "" {
-		cmdArgs = append(cmdArgs, fmt.Sprintf("--access-token %sPLUGIN_ACCESS_TOKEN", envPrefix))
-	} else {
-		return fmt.Errorf("either username/password, api key or access token needs to be set")
+	// Wait before doing any work if a startup delay is configured
+	delay, err := parseDuration(args.StartupDelay)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing startup delay: %s", err)
+	}
+	timeout, err := parseDuration(args.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing timeout: %s", err)
+	}
+	if delay > 0 {
+		logrus.Infof("Waiting %s before starting", delay)
+		if err := sleep(ctx, delay); err != nil {
+			return nil, err
+		}
 	}
 
-	flat := parseBoolOrDefault(false, args.Flat)
-	cmdArgs = append(cmdArgs, fmt.Sprintf("--flat=%s", strconv.FormatBool(flat)))
+	bin, err := jfrogBin(args)
+	if err != nil {
+		return nil, err
+	}
 
-	if args.Threads > 0 {
-		cmdArgs = append(cmdArgs, fmt.Sprintf("--threads=%d", args.Threads))
+	// Remove the files created by the run on exit, so that no
+	// state is left behind on shared runners
+	var created cleanup
+	defer created.remove()
+
+	// Merge multiple spec files into a single spec for the cli
+	if specs := splitList(args.Spec, ","); len(specs) > 1 {
+		for i, spec := range specs {
+			specs[i] = resolvePath(args, spec)
+		}
+		path, err := writeMergedSpec(specs)
+		if err != nil {
+			return nil, err
+		}
+		created.add(path)
+		args.Spec = path
+	}
+
+	cmds, err := buildCommands(args)
+	if err != nil {
+		return nil, err
+	}
+
+	// Print the resolved commands without running them
+	if parseBoolOrDefault(false, args.ValidateOnly) {
+		logrus.Infoln("Validate only is set, printing the commands without running them.")
+		for _, cmdArgs := range cmds {
+			trace(bin, cmdArgs, secrets(args)...)
+		}
+		return &ExecResult{Operation: operation(args), Duration: time.Since(start)}, nil
+	}
+
+	// Isolate the jfrog config from other steps on the runner
+	if args.ConfigHome == "" {
+		home, err := os.MkdirTemp("", "jfrog")
+		if err != nil {
+			return nil, fmt.Errorf("error creating config home: %s", err)
+		}
+		created.add(home)
+		args.ConfigHome = home
+	}
+
+	pemPaths, err := writePEMFile(args)
+	// An explicit pem file path is left in place for later steps
+	if args.PEMFilePath == "" {
+		created.add(pemPaths...)
+	}
+	if err != nil {
+		return nil, err
+	}
+	trustedPaths, err := trustPEMFile(args)
+	created.add(trustedPaths...)
+	if err != nil {
+		return nil, err
+	}
+
+	mavenPaths, err := writeMavenConfig(args)
+	created.add(mavenPaths...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Save the command output for debugging failed runs
+	if args.LogFile != "" {
+		if err := createLogFile(args.LogFile); err != nil {
+			return nil, err
+		}
+	}
+
+	// Kill the command if it runs longer than the timeout
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	// Check the server is reachable and accepts the credentials
+	if parseBoolOrDefault(false, args.Ping) {
+		pingArgs, err := pingCommand(args)
+		if err != nil {
+			return nil, err
+		}
+		if err := run(ctx, args, bin, pingArgs, os.Stdout, os.Stderr); err != nil {
+			return nil, fmt.Errorf("error pinging %s, check the url and credentials: %s", args.URL, err)
+		}
+	}
+
+	// Never overwrite the artifacts at the target
+	if abortOnExisting(args) {
+		if err := checkExisting(ctx, args, bin); err != nil {
+			return nil, err
+		}
 	}
-	// Set insecure flag
-	insecure := parseBoolOrDefault(false, args.Insecure)
-	if insecure {
-		cmdArgs = append(cmdArgs, "--insecure-tls")
-	}
-	// create pem file
-	if args.PEMFileContents != "" && !insecure {
-		var path string
-		// figure out path to write pem file
-		if args.PEMFilePath == "" {
-			if runtime.GOOS == "windows" {
-				path = "C:/users/ContainerAdministrator/.jfrog/security/certs/cert.pem"
-			} else {
-				path = "/root/.jfrog/security/certs/cert.pem"
+
+	// Check how many artifacts sync deletes removes before
+	// removing any of them
+	if syncDeletesThreshold(args) {
+		if err := checkSyncDeletes(ctx, args, bin); err != nil {
+			return nil, err
+		}
+	}
+
+	// Stream the output to stdout, and write it to the sink once
+	// the command succeeds, so that the output of failed attempts
+	// is not mixed into the captured output or the output file.
+	stdout := io.Writer(os.Stdout)
+	var sink io.Writer
+
+	// Capture the output instead of streaming it, so that it can
+	// be re-emitted and written to the output file as a whole.
+	var captured bytes.Buffer
+	jsonOutput := args.OutputFormat == formatJSON
+	if jsonOutput {
+		stdout = io.Discard
+		sink = &captured
+	}
+
+	// Write the search results to the output file
+	if writesResults(args) && args.OutputFile != "" && !jsonOutput {
+		f, err := createOutputFile(args.OutputFile)
+		if err != nil {
+			return nil, err
+		}
+		defer f.Close()
+		sink = f
+	}
+
+	// Run every command, even if an earlier one fails, and
+	// return the aggregated errors.
+	var errs multiError
+	summarize := detailedSummary(args)
+	summary := new(Summary)
+	var raw bytes.Buffer
+	for _, cmdArgs := range cmds {
+		// Capture the output for the result, and the detailed
+		// summary or the scan results printed by the jfrog cli
+		var output bytes.Buffer
+		w := io.MultiWriter(stdout, &output)
+		scan := isScan(args, cmdArgs)
+		if isPublishBuild(cmdArgs) && len(errs) > 0 {
+			logrus.Warnln("Upload failed, skipping the build info publish.")
+			continue
+		}
+		err := retry(ctx, args.PluginRetries, func() error {
+			output.Reset()
+			return run(ctx, args, bin, cmdArgs, w, os.Stderr)
+		})
+		raw.Write(output.Bytes())
+		switch {
+		case err == nil && sink != nil:
+			if _, err := sink.Write(output.Bytes()); err != nil {
+				errs = append(errs, fmt.Errorf("error writing output: %s", err))
 			}
-		} else {
-			path = args.PEMFilePath
-		}
-		fmt.Printf("Creating pem file at %q\n", path)
-		// write pen contents to path
-		if _, err := os.Stat(path); os.IsNotExist(err) {
-			// remove filename from path
-			dir := filepath.Dir(path)
-			pemFolderErr := os.MkdirAll(dir, 0700)
-			if pemFolderErr != nil {
-				return fmt.Errorf("error creating pem folder: %s", pemFolderErr)
+		case err != nil && jsonOutput:
+			// Keep the output of the failed command visible without
+			// adding it to the json output
+			os.Stderr.Write(output.Bytes())
+		}
+		if err != nil {
+			var exitErr *ExitError
+			if len(cmds) > 1 && !errors.As(err, &exitErr) {
+				err = fmt.Errorf("error running %s: %s", strings.Join(redact(cmdArgs, secrets(args)...), " "), err)
+			}
+			errs = append(errs, err)
+			continue
+		}
+		if summarize && !isConfig(cmdArgs) && !isPublishBuild(cmdArgs) {
+			result, err := parseSummary(output.Bytes())
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			summary.merge(result)
+		}
+		if scan {
+			results, err := parseScan(output.Bytes())
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if err := checkScan(args, results); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	if summarize && len(errs) < len(cmds) {
+		if err := reportSummary(args, summary); err != nil {
+			errs = append(errs, err)
+		}
+		if verifyChecksum(args) {
+			if err := verifyChecksums(commandDir(args), summary.Files); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	if jsonOutput {
+		os.Stdout.Write(captured.Bytes())
+		if args.OutputFile != "" && !summarize {
+			if err := writeOutput(args.OutputFile, captured.Bytes()); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	result := &ExecResult{
+		Operation: operation(args),
+		Duration:  time.Since(start),
+		RawOutput: raw.String(),
+	}
+	if summarize {
+		result.FilesAffected = summary.Totals.Success
+		result.Summary = summary
+	}
+	switch len(errs) {
+	case 0:
+		return result, nil
+	case 1:
+		return result, errs[0]
+	default:
+		return result, errs
+	}
+}
+
+// operation returns the name of the plugin command.
+func operation(args Args) string {
+	if args.Command == "" {
+		return commandUpload
+	}
+	return args.Command
+}
+
+// run runs a single jfrog command and writes its output to stdout
+// and its logs to stderr. Both are written to the log file as well,
+// when set.
+func run(ctx context.Context, args Args, bin string, cmdArgs []string, stdout, stderr io.Writer) error {
+	if args.LogFile != "" {
+		f, err := openLogFile(args.LogFile)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		stdout = io.MultiWriter(stdout, f)
+		stderr = io.MultiWriter(stderr, f)
+	}
+
+	env := commandEnv(args)
+	for _, e := range env {
+		logrus.Debugf("Setting environment variable %s", maskEnv(e))
+	}
+
+	logrus.Debugf("Running %s with arguments %q", bin, redact(cmdArgs, secrets(args)...))
+	trace(bin, cmdArgs, secrets(args)...)
+
+	err := newRunner(commandDir(args), stdout, stderr).Run(ctx, bin, cmdArgs, append(os.Environ(), env...))
+	var exitErr interface{ ExitCode() int }
+	if errors.As(err, &exitErr) {
+		return &ExitError{
+			Command: strings.Join(redact(cmdArgs, secrets(args)...), " "),
+			Code:    exitErr.ExitCode(),
+			Err:     err,
+		}
+	}
+	return redactError(err, secrets(args)...)
+}
+
+// retryBackoff is the wait before the first retry. It doubles
+// with each further attempt.
+var retryBackoff = 2 * time.Second
+
+// maxRetryBackoff is the upper bound of the wait between retries.
+const maxRetryBackoff = time.Minute
+
+// retry calls fn until it succeeds or the retries are exhausted,
+// waiting with exponential backoff and jitter between attempts.
+func retry(ctx context.Context, retries int, fn func() error) error {
+	for attempt := 0; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= retries {
+			return err
+		}
+		wait := backoff(attempt)
+		logrus.Warnf("Attempt %d of %d failed, retrying in %s: %s", attempt+1, retries+1, wait, err)
+		if err := sleep(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+// backoff returns the wait before the retry following the attempt.
+func backoff(attempt int) time.Duration {
+	wait := retryBackoff << uint(attempt)
+	if wait <= 0 || wait > maxRetryBackoff {
+		wait = maxRetryBackoff
+	}
+	// add up to 50% jitter so concurrent steps do not retry in lockstep
+	if half := int64(wait / 2); half > 0 {
+		wait += time.Duration(rand.Int63n(half))
+	}
+	return wait
+}
+
+// commandDir returns the directory the jfrog command runs in. The
+// commands run in the working directory, and the package manager
+// commands in the build directory, relative to the working
+// directory.
+func commandDir(args Args) string {
+	switch args.Command {
+	case commandNpmPublish, commandMaven, commandGoPublish, commandPipInstall, commandPipPublish:
+		if args.BuildDir != "" {
+			if filepath.IsAbs(args.BuildDir) || args.WorkingDir == "" {
+				return args.BuildDir
 			}
-			// write pem contents
-			pemWriteErr := os.WriteFile(path, []byte(args.PEMFileContents), 0600)
-			if pemWriteErr != nil {
-				return fmt.Errorf("error writing pem file: %s", pemWriteErr)
+			return filepath.Join(args.WorkingDir, args.BuildDir)
+		}
+	}
+	return args.WorkingDir
+}
+
+// resolvePath returns the path resolved against the directory the
+// jfrog command runs in, so that the plugin reads and checks the
+// same file as the jfrog cli. Absolute paths are unchanged.
+func resolvePath(args Args, path string) string {
+	dir := commandDir(args)
+	if dir == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+// commandEnv returns the environment variables added to the
+// environment of the jfrog command. The config home and proxy
+// variables are only set when configured, so existing values are
+// otherwise kept.
+func commandEnv(args Args) []string {
+	env := []string{"JFROG_CLI_OFFER_CONFIG=false"}
+	if args.ConfigHome != "" {
+		env = append(env, "JFROG_CLI_HOME_DIR="+args.ConfigHome)
+	}
+	if args.HTTPProxy != "" {
+		env = append(env, "HTTP_PROXY="+args.HTTPProxy)
+	}
+	if args.HTTPSProxy != "" {
+		env = append(env, "HTTPS_PROXY="+args.HTTPSProxy)
+	}
+	if args.NoProxy != "" {
+		env = append(env, "NO_PROXY="+args.NoProxy)
+	}
+	if level := cliLogLevel(args.Level); level != "" {
+		env = append(env, "JFROG_CLI_LOG_LEVEL="+level)
+	}
+	// Quiet disables the progress bars and the new version warning,
+	// and is passed as --quiet to the commands that prompt.
+	if parseBoolOrDefault(false, args.Quiet) {
+		env = append(env, "CI=true", "JFROG_CLI_AVOID_NEW_VERSION_WARNING=true")
+	}
+	return env
+}
+
+// maskEnv returns the environment variable with the userinfo of a
+// proxy url masked, so that the proxy credentials are not logged. A
+// proxy value with credentials that cannot be parsed as a url is
+// masked as a whole.
+func maskEnv(e string) string {
+	key, value, _ := strings.Cut(e, "=")
+	if key != "HTTP_PROXY" && key != "HTTPS_PROXY" {
+		return e
+	}
+	u, err := url.Parse(value)
+	if err != nil || (u.User == nil && strings.Contains(value, "@")) {
+		return key + "=****"
+	}
+	if u.User == nil {
+		return e
+	}
+	u.User = nil
+	return key + "=" + strings.Replace(u.String(), "//", "//****@", 1)
+}
+
+// buildCommands returns the jfrog cli arguments for each command
+// to run. An upload with multiple sources, a source manifest or a
+// props map runs one upload per source, and curl, aql and scan run
+// after the server is configured. When a server id is set, every
+// command that connects to the server runs after the server is
+// configured, and the package manager commands always do. The build
+// info is published after the upload when publish build info is set.
+func buildCommands(args Args) ([][]string, error) {
+	cmds, err := jfrogCommands(args)
+	if err != nil {
+		return nil, err
+	}
+	if publishBuildInfo(args) {
+		cmdArgs, err := publishBuildCommand(args)
+		if err != nil {
+			return nil, err
+		}
+		cmds = append(cmds, cmdArgs)
+	}
+	switch args.Command {
+	case commandCurl, commandAQL, commandScan:
+	default:
+		if (args.ServerID != "" && needsServer(args)) || usesProjectConfig(args) {
+			configArgs, err := configCommand(args)
+			if err != nil {
+				return nil, err
+			}
+			cmds = append([][]string{configArgs}, cmds...)
+		}
+	}
+	return cmds, nil
+}
+
+// jfrogCommands returns the jfrog cli arguments for each command
+// to run, without configuring the server.
+func jfrogCommands(args Args) ([][]string, error) {
+	switch args.Command {
+	case commandCurl:
+		return curlCommands(args)
+	case commandAQL:
+		return aqlCommands(args)
+	case commandScan:
+		return scanCommands(args)
+	}
+	if isUpload(args) && args.PropsMap != "" {
+		cmds, err := propsMapCommands(args)
+		if err != nil {
+			return nil, err
+		}
+		return cmds, checkSyncUploads(args, cmds)
+	}
+	// Split the sources on newlines only, since commas are valid in
+	// file names and regexp patterns.
+	sources := splitList(args.Source, "\n")
+	if isUpload(args) && args.SourceManifest != "" {
+		var err error
+		path := args.SourceManifest
+		if path != "-" {
+			path = resolvePath(args, path)
+		}
+		sources, err = manifestSources(path)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if isUpload(args) && args.Spec == "" && (len(sources) > 1 || args.SourceManifest != "") {
+		var cmds [][]string
+		for _, source := range sources {
+			a := args
+			a.Source = source
+			cmdArgs, err := buildCommand(a)
+			if err != nil {
+				return nil, err
 			}
-			fmt.Printf("Successfully created pem file at %q\n", path)
+			cmds = append(cmds, cmdArgs)
 		}
+		return cmds, checkSyncUploads(args, cmds)
 	}
+	cmdArgs, err := buildCommand(args)
+	if err != nil {
+		return nil, err
+	}
+	return [][]string{cmdArgs}, nil
+}
+
+// checkSyncUploads returns an error if sync deletes is set for more
+// than one upload, since each upload would delete the artifacts
+// uploaded by the ones before it.
+func checkSyncUploads(args Args, cmds [][]string) error {
+	if args.SyncDeletes != "" && len(cmds) > 1 {
+		return fmt.Errorf("sync deletes needs a single source, got %d uploads", len(cmds))
+	}
+	return nil
+}
+
+// composeTarget returns the target, or the repo and path joined as
+// <repo>/<path> when the target is not set and the command target is
+// an artifactory path.
+func composeTarget(args Args) string {
+	if args.RepoKey == "" && args.Path == "" {
+		return args.Target
+	}
+	if args.Target != "" {
+		logrus.Warnln("Target is set, ignoring the repo and path.")
+		return args.Target
+	}
+	switch args.Command {
+	case "", commandUpload, commandCopy, commandMove, commandDelete, commandSearch:
+	default:
+		return args.Target
+	}
+	repo := strings.Trim(args.RepoKey, "/")
+	if repo == "" {
+		return ""
+	}
+	return repo + "/" + strings.TrimLeft(args.Path, "/")
+}
+
+// isUpload returns true if the plugin command is upload.
+func isUpload(args Args) bool {
+	return args.Command == "" || args.Command == commandUpload
+}
+
+// buildCommand returns the jfrog cli arguments for the
+// configured plugin command.
+func buildCommand(args Args) ([]string, error) {
+	switch args.Command {
+	case "", commandUpload:
+		return uploadCommand(args)
+	case commandDownload:
+		return downloadCommand(args)
+	case commandCopy:
+		return copyCommand(args, "cp")
+	case commandMove:
+		return copyCommand(args, "mv")
+	case commandDelete:
+		return deleteCommand(args)
+	case commandSearch:
+		return searchCommand(args)
+	case commandSetProps:
+		return setPropsCommand(args)
+	case commandDelProps:
+		return deletePropsCommand(args)
+	case commandPublishBuild:
+		return publishBuildCommand(args)
+	case commandCollectEnv:
+		return collectEnvCommand(args)
+	case commandPromote:
+		return promoteCommand(args)
+	case commandCollectGit:
+		return collectGitCommand(args)
+	case commandDiscard:
+		return discardBuildsCommand(args)
+	case commandCollectDeps:
+		return collectDepsCommand(args)
+	case commandBuildAppend:
+		return buildAppendCommand(args)
+	case commandDockerPush:
+		return dockerPushCommand(args)
+	case commandDockerPull:
+		return dockerPullCommand(args)
+	case commandNpmPublish:
+		return npmPublishCommand(args)
+	case commandMaven:
+		return mavenCommand(args)
+	case commandGoPublish:
+		return goPublishCommand(args)
+	case commandPipInstall:
+		return pipInstallCommand(args)
+	case commandPipPublish:
+		return pipPublishCommand(args)
+	case commandBundleCreate:
+		return bundleCreateCommand(args)
+	case commandBundleSign:
+		return bundleSignCommand(args)
+	case commandBundleDist:
+		return bundleDistributeCommand(args)
+	case commandBundleDelete:
+		return bundleDeleteCommand(args)
+	case commandBuildScan:
+		return buildScanCommand(args)
+	case commandLFSClean:
+		return lfsCleanCommand(args)
+	default:
+		return nil, fmt.Errorf("unsupported command %q", args.Command)
+	}
+}
+
+// uploadCommand returns the jfrog rt u arguments.
+func uploadCommand(args Args) ([]string, error) {
+	if parseBoolOrDefault(false, args.Explode) {
+		return nil, fmt.Errorf("explode can only be set for download")
+	}
+	if parseBoolOrDefault(false, args.ValidateSymlinks) {
+		return nil, fmt.Errorf("validate symlinks can only be set for download")
+	}
+	cmdArgs, err := baseCommand(args, "rt", "u")
+	if err != nil {
+		return nil, err
+	}
+	cmdArgs = append(cmdArgs, transferArgs(args)...)
+	cmdArgs = append(cmdArgs, recursiveArgs(args)...)
+	cmdArgs = append(cmdArgs, exclusionsArgs(args)...)
+
+	patternArgs, err := patternTypeArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	cmdArgs = append(cmdArgs, patternArgs...)
+
+	splitArgs, err := splitArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	cmdArgs = append(cmdArgs, splitArgs...)
+
+	if args.TargetProps != "" {
+		cmdArgs = append(cmdArgs, fmt.Sprintf("--target-props=%s", args.TargetProps))
+	}
+	// Symlinks are read from the local file system, so only apply
+	// to upload, and validated on download.
+	if parseBoolOrDefault(false, args.Symlinks) {
+		cmdArgs = append(cmdArgs, "--symlinks=true")
+	}
+	if parseBoolOrDefault(false, args.IncludeDirs) {
+		cmdArgs = append(cmdArgs, "--include-dirs=true")
+	}
+	if args.Deb != "" {
+		if parts := strings.Split(args.Deb, "/"); len(parts) != 3 || parts[0] == "" || parts[1] == "" || parts[2] == "" {
+			return nil, fmt.Errorf("invalid deb %q, must be distribution/component/architecture", args.Deb)
+		}
+		cmdArgs = append(cmdArgs, fmt.Sprintf("--deb=%s", args.Deb))
+	}
+	// The cli compresses the archive with the default level and
+	// has no flag to change it.
+	switch args.Archive {
+	case "":
+	case "zip":
+		cmdArgs = append(cmdArgs, fmt.Sprintf("--archive=%s", args.Archive))
+	default:
+		return nil, fmt.Errorf("unsupported archive type %q, must be zip", args.Archive)
+	}
+	// The uploaded archive is extracted server side
+	if parseBoolOrDefault(false, args.ExplodeUpload) {
+		if args.Archive != "" {
+			return nil, fmt.Errorf("explode upload cannot be set with archive")
+		}
+		cmdArgs = append(cmdArgs, "--explode=true")
+	}
+	cmdArgs = append(cmdArgs, buildArgs(args)...)
+	if uploadDryRun(args) {
+		cmdArgs = append(cmdArgs, "--dry-run")
+	}
+	cmdArgs = append(cmdArgs, summaryArgs(args)...)
+	cmdArgs = append(cmdArgs, failNoOpArgs(args)...)
+	cmdArgs = append(cmdArgs, projectArgs(args)...)
+
+	if args.SyncDeletes != "" {
+		cmdArgs = append(cmdArgs, fmt.Sprintf("--sync-deletes=%s", args.SyncDeletes))
+		if parseBoolOrDefault(false, args.Quiet) {
+			cmdArgs = append(cmdArgs, "--quiet")
+		} else {
+			logrus.Warnln("Sync deletes is not confirmed, running the upload as a dry run. Set quiet to true to delete remote artifacts.")
+		}
+	}
+
 	// Take in spec file or use source/target arguments
 	if args.Spec != "" {
-		cmdArgs = append(cmdArgs, fmt.Sprintf("--spec=%s", args.Spec))
-		if args.SpecVars != "" {
-			cmdArgs = append(cmdArgs, fmt.Sprintf("--spec-vars='%s'", args.SpecVars))
+		specArgs, err := specArgs(args)
+		if err != nil {
+			return nil, err
 		}
+		cmdArgs = append(cmdArgs, specArgs...)
 	} else {
 		if args.Source == "" {
-			return fmt.Errorf("source file needs to be set")
+			return nil, fmt.Errorf("source file needs to be set")
 		}
 		if args.Target == "" {
-			return fmt.Errorf("target path needs to be set")
+			return nil, fmt.Errorf("target path needs to be set")
 		}
-		cmdArgs = append(cmdArgs, fmt.Sprintf("\"%s\"", args.Source), args.Target)
+		cmdArgs = append(cmdArgs, args.Source, args.Target)
 	}
+	return cmdArgs, nil
+}
 
-	cmdStr := strings.Join(cmdArgs[:], " ")
+// downloadCommand returns the jfrog rt dl arguments. The source
+// is the artifactory path and the target is the local destination.
+func downloadCommand(args Args) ([]string, error) {
+	if err := checkUploadOnly(args); err != nil {
+		return nil, err
+	}
+	cmdArgs, err := baseCommand(args, "rt", "dl")
+	if err != nil {
+		return nil, err
+	}
+	cmdArgs = append(cmdArgs, transferArgs(args)...)
+	cmdArgs = append(cmdArgs, recursiveArgs(args)...)
+	cmdArgs = append(cmdArgs, exclusionsArgs(args)...)
 
-	shell, shArg := getShell()
+	if parseBoolOrDefault(false, args.Explode) {
+		cmdArgs = append(cmdArgs, "--explode=true")
+	}
+	if parseBoolOrDefault(false, args.ValidateSymlinks) {
+		cmdArgs = append(cmdArgs, "--validate-symlinks=true")
+	}
+	cmdArgs = append(cmdArgs, buildArgs(args)...)
+	cmdArgs = append(cmdArgs, summaryArgs(args)...)
+	cmdArgs = append(cmdArgs, failNoOpArgs(args)...)
+	cmdArgs = append(cmdArgs, projectArgs(args)...)
 
-	cmd := exec.Command(shell, shArg, cmdStr)
-	cmd.Env = os.Environ()
-	cmd.Env = append(cmd.Env, "JFROG_CLI_OFFER_CONFIG=false")
+	// Take in spec file or use source/target arguments
+	if args.Spec != "" {
+		specArgs, err := specArgs(args)
+		if err != nil {
+			return nil, err
+		}
+		cmdArgs = append(cmdArgs, specArgs...)
+	} else {
+		if args.Source == "" {
+			return nil, fmt.Errorf("source path needs to be set")
+		}
+		cmdArgs = append(cmdArgs, args.Source)
+		if args.Target != "" {
+			cmdArgs = append(cmdArgs, args.Target)
+		}
+	}
+	return cmdArgs, nil
+}
 
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
-	trace(cmd)
+// checkUploadOnly returns an error if an option that only applies
+// to the upload command is set.
+func checkUploadOnly(args Args) error {
+	if args.TargetProps != "" {
+		return fmt.Errorf("target props can only be set for upload")
+	}
+	if args.PatternType != "" && args.PatternType != patternWildcard {
+		return fmt.Errorf("pattern type %q can only be set for upload", args.PatternType)
+	}
+	if args.Archive != "" {
+		return fmt.Errorf("archive can only be set for upload")
+	}
+	if parseBoolOrDefault(false, args.Symlinks) {
+		return fmt.Errorf("symlinks can only be set for upload")
+	}
+	if parseBoolOrDefault(false, args.IncludeDirs) {
+		return fmt.Errorf("include dirs can only be set for upload")
+	}
+	if args.Deb != "" {
+		return fmt.Errorf("deb can only be set for upload")
+	}
+	if args.SourceManifest != "" {
+		return fmt.Errorf("source manifest can only be set for upload")
+	}
+	if args.PropsMap != "" {
+		return fmt.Errorf("props map can only be set for upload")
+	}
+	if parseBoolOrDefault(false, args.ExplodeUpload) {
+		return fmt.Errorf("explode upload can only be set for upload")
+	}
+	return nil
+}
 
-	err := cmd.Run()
-	return err
+// defaultServerID is the id of the server configured for the
+// commands that do not accept the server url and credentials as
+// flags, when no server id is set.
+const defaultServerID = "drone"
+
+// serverID returns the id of the server configured in the config
+// home of the run.
+func serverID(args Args) string {
+	if args.ServerID != "" {
+		return args.ServerID
+	}
+	return defaultServerID
 }
 
-func getShell() (string, string) {
-	if runtime.GOOS == "windows" {
-		return "powershell", "-Command"
+// configCommand returns the jfrog config add arguments used to
+// configure the server in the config home of the run. The url is the
+// artifactory url, as for the other commands, while the platform url
+// is the base url of the jfrog platform the cli derives the xray url
+// from.
+func configCommand(args Args) ([]string, error) {
+	authArgs, err := buildAuthArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	cmdArgs := []string{"config", "add", serverID(args), fmt.Sprintf("--artifactory-url=%s", args.URL)}
+	if args.PlatformURL != "" {
+		cmdArgs = append(cmdArgs, fmt.Sprintf("--url=%s", args.PlatformURL))
 	}
+	cmdArgs = append(cmdArgs, authArgs...)
+	return append(cmdArgs, "--interactive=false", "--overwrite=true"), nil
+}
 
-	return "sh", "-c"
+// isConfig returns true if the command configures the server.
+func isConfig(cmdArgs []string) bool {
+	return len(cmdArgs) > 1 && cmdArgs[0] == "config" && cmdArgs[1] == "add"
 }
 
-func getJfrogBin() string {
-	if runtime.GOOS == "windows" {
-		return "C:/bin/jfrog.exe"
+// pingCommand returns the jfrog rt ping arguments used to check
+// the server is reachable with the configured credentials. The
+// ping runs before the server is configured, so it always passes
+// the url and credentials as flags.
+func pingCommand(args Args) ([]string, error) {
+	args.ServerID = ""
+	return baseCommand(args, "rt", "ping")
+}
+
+// copyCommand returns the jfrog rt cp or rt mv arguments. The copy
+// or move happens server side, so both the source and target are
+// artifactory paths.
+func copyCommand(args Args, subcommand string) ([]string, error) {
+	cmdArgs, err := baseCommand(args, "rt", subcommand)
+	if err != nil {
+		return nil, err
+	}
+	cmdArgs = append(cmdArgs, transferArgs(args)...)
+	cmdArgs = append(cmdArgs, recursiveArgs(args)...)
+	cmdArgs = append(cmdArgs, dryRunArgs(args)...)
+	cmdArgs = append(cmdArgs, failNoOpArgs(args)...)
+
+	if args.Source == "" {
+		return nil, fmt.Errorf("source path needs to be set")
+	}
+	if args.Target == "" {
+		return nil, fmt.Errorf("target path needs to be set")
+	}
+	cmdArgs = append(cmdArgs, args.Source, args.Target)
+	return cmdArgs, nil
+}
+
+// deleteCommand returns the jfrog rt del arguments. The target, or
+// the source if no target is set, is used as the deletion pattern.
+// The deletion needs to be confirmed, see confirmArgs.
+func deleteCommand(args Args) ([]string, error) {
+	cmdArgs, err := baseCommand(args, "rt", "del")
+	if err != nil {
+		return nil, err
+	}
+	cmdArgs = append(cmdArgs, failNoOpArgs(args)...)
+	cmdArgs = append(cmdArgs, retryArgs(args)...)
+	if args.Threads > 0 {
+		cmdArgs = append(cmdArgs, fmt.Sprintf("--threads=%d", args.Threads))
+	}
+	cmdArgs = append(cmdArgs, recursiveArgs(args)...)
+	cmdArgs = append(cmdArgs, exclusionsArgs(args)...)
+
+	cmdArgs = append(cmdArgs, confirmArgs(args, "Delete is not confirmed, listing matched artifacts only. Set quiet to true to delete them.")...)
+
+	pattern, err := patternArg(args)
+	if err != nil {
+		return nil, err
+	}
+	return append(cmdArgs, pattern), nil
+}
+
+// searchCommand returns the jfrog rt s arguments. The target, or
+// the source if no target is set, is used as the search pattern.
+func searchCommand(args Args) ([]string, error) {
+	cmdArgs, err := baseCommand(args, "rt", "s")
+	if err != nil {
+		return nil, err
+	}
+	cmdArgs = append(cmdArgs, retryArgs(args)...)
+	cmdArgs = append(cmdArgs, recursiveArgs(args)...)
+	cmdArgs = append(cmdArgs, exclusionsArgs(args)...)
+
+	pattern, err := patternArg(args)
+	if err != nil {
+		return nil, err
+	}
+	return append(cmdArgs, pattern), nil
+}
+
+// setPropsCommand returns the jfrog rt sp arguments used to set
+// properties on artifacts matching the target pattern.
+func setPropsCommand(args Args) ([]string, error) {
+	cmdArgs, err := baseCommand(args, "rt", "sp")
+	if err != nil {
+		return nil, err
+	}
+	cmdArgs = append(cmdArgs, recursiveArgs(args)...)
+	cmdArgs = append(cmdArgs, exclusionsArgs(args)...)
+
+	pattern, err := patternArg(args)
+	if err != nil {
+		return nil, err
+	}
+	if args.Props == "" {
+		return nil, fmt.Errorf("props needs to be set")
+	}
+	return append(cmdArgs, pattern, args.Props), nil
+}
+
+// deletePropsCommand returns the jfrog rt delp arguments used to
+// remove the comma separated property keys from artifacts matching
+// the target pattern.
+func deletePropsCommand(args Args) ([]string, error) {
+	cmdArgs, err := baseCommand(args, "rt", "delp")
+	if err != nil {
+		return nil, err
+	}
+	cmdArgs = append(cmdArgs, recursiveArgs(args)...)
+
+	pattern, err := patternArg(args)
+	if err != nil {
+		return nil, err
+	}
+	if args.PropKeys == "" {
+		return nil, fmt.Errorf("prop keys needs to be set")
+	}
+	return append(cmdArgs, pattern, args.PropKeys), nil
+}
+
+// patternArg returns the artifactory pattern taken from the
+// target, or the source if no target is set.
+func patternArg(args Args) (string, error) {
+	pattern := args.Target
+	if pattern == "" {
+		pattern = args.Source
+	}
+	if pattern == "" {
+		return "", fmt.Errorf("target path needs to be set")
+	}
+	return pattern, nil
+}
+
+// credentials returns the credential types that are set, in order
+// of precedence.
+func credentials(args Args) []string {
+	var creds []string
+	if args.Username != "" && args.Password != "" {
+		creds = append(creds, "username/password")
+	}
+	if args.APIKey != "" {
+		creds = append(creds, "api key")
+	}
+	if args.AccessToken != "" {
+		creds = append(creds, "access token")
+	}
+	if args.IdentityToken != "" {
+		creds = append(creds, "identity token")
+	}
+	return creds
+}
+
+// warnCredentials logs a warning when more than one credential
+// type is set, naming the one that is used.
+func warnCredentials(args Args) {
+	if creds := credentials(args); len(creds) > 1 {
+		logrus.Warnf("Multiple credentials are set (%s), using %s", strings.Join(creds, ", "), creds[0])
 	}
-	return "jfrog"
 }
 
-func getEnvPrefix() string {
+// baseCommand returns the jfrog cli subcommand followed by the
+// server url, authentication and tls flags. When a server id is set
+// the configured server is used instead of the url and credentials.
+func baseCommand(args Args, subcommand ...string) ([]string, error) {
+	cmdArgs := append([]string{}, subcommand...)
+	if args.ServerID != "" {
+		cmdArgs = append(cmdArgs, fmt.Sprintf("--server-id=%s", args.ServerID))
+		return append(cmdArgs, insecureArgs(args)...), nil
+	}
+	authArgs, err := buildAuthArgs(args)
+	if err != nil {
+		return nil, err
+	}
+	cmdArgs = append(cmdArgs, fmt.Sprintf("--url=%s", args.URL))
+	return append(cmdArgs, authArgs...), nil
+}
+
+// buildAuthArgs returns the authentication and tls flags. Only the
+// first configured credential is used. A pem file is trusted from
+// the certs directory and needs no flag.
+func buildAuthArgs(args Args) ([]string, error) {
+	var cmdArgs []string
+	if args.Username != "" && args.Password != "" {
+		cmdArgs = append(cmdArgs, fmt.Sprintf("--user=%s", args.Username))
+		cmdArgs = append(cmdArgs, fmt.Sprintf("--password=%s", args.Password))
+	} else if args.APIKey != "" {
+		cmdArgs = append(cmdArgs, fmt.Sprintf("--apikey=%s", args.APIKey))
+	} else if args.AccessToken != "" {
+		cmdArgs = append(cmdArgs, fmt.Sprintf("--access-token=%s", args.AccessToken))
+	} else if args.IdentityToken != "" {
+		// identity tokens are accepted as access tokens
+		cmdArgs = append(cmdArgs, fmt.Sprintf("--access-token=%s", args.IdentityToken))
+	} else {
+		return nil, fmt.Errorf("either username/password, api key, access token or identity token needs to be set")
+	}
+	return append(cmdArgs, insecureArgs(args)...), nil
+}
+
+// insecureArgs returns the flag that skips tls verification when
+// insecure is set.
+func insecureArgs(args Args) []string {
+	if parseBoolOrDefault(false, args.Insecure) {
+		return []string{"--insecure-tls"}
+	}
+	return nil
+}
+
+// transferArgs returns the flags shared by the upload and
+// download commands.
+func transferArgs(args Args) []string {
+	var cmdArgs []string
+	cmdArgs = append(cmdArgs, retryArgs(args)...)
+
+	// Only set flat when configured, so that the cli and spec
+	// defaults apply otherwise.
+	if args.Flat != "" {
+		flat := parseBoolOrDefault(false, args.Flat)
+		cmdArgs = append(cmdArgs, fmt.Sprintf("--flat=%s", strconv.FormatBool(flat)))
+	}
+
+	if args.Threads > 0 {
+		cmdArgs = append(cmdArgs, fmt.Sprintf("--threads=%d", args.Threads))
+	}
+	return cmdArgs
+}
+
+// retryArgs returns the http retry flags. The retry wait time is
+// set in seconds.
+func retryArgs(args Args) []string {
+	var cmdArgs []string
+	if args.Retries != 0 {
+		cmdArgs = append(cmdArgs, fmt.Sprintf("--retries=%d", args.Retries))
+	}
+	if args.RetryWaitTime > 0 {
+		cmdArgs = append(cmdArgs, fmt.Sprintf("--retry-wait-time=%ds", args.RetryWaitTime))
+	}
+	return cmdArgs
+}
+
+// recursiveArgs returns the recursive flag when it is explicitly
+// set, otherwise the jfrog cli default applies.
+func recursiveArgs(args Args) []string {
+	if args.Recursive == "" {
+		return nil
+	}
+	recursive := parseBoolOrDefault(true, args.Recursive)
+	return []string{fmt.Sprintf("--recursive=%s", strconv.FormatBool(recursive))}
+}
+
+// patternTypeArgs returns the flag that selects how the jfrog cli
+// interprets the source pattern. Wildcard is the cli default.
+func patternTypeArgs(args Args) ([]string, error) {
+	switch args.PatternType {
+	case "", patternWildcard:
+		return nil, nil
+	case patternRegexp:
+		return []string{"--regexp"}, nil
+	case patternAnt:
+		return []string{"--ant"}, nil
+	default:
+		return nil, fmt.Errorf("unsupported pattern type %q, must be one of wildcard, regexp or ant", args.PatternType)
+	}
+}
+
+// splitArgs returns the multipart upload flags. The minimum split
+// size is in KB and the chunk size of each part in MB.
+func splitArgs(args Args) ([]string, error) {
+	if args.MinSplit < 0 {
+		return nil, fmt.Errorf("min split must not be negative")
+	}
+	if args.SplitCount < 0 {
+		return nil, fmt.Errorf("split count must not be negative")
+	}
+	if args.ChunkSize < 0 {
+		return nil, fmt.Errorf("chunk size must not be negative")
+	}
+	var cmdArgs []string
+	if args.MinSplit > 0 {
+		cmdArgs = append(cmdArgs, fmt.Sprintf("--min-split=%d", args.MinSplit))
+	}
+	if args.SplitCount > 0 {
+		cmdArgs = append(cmdArgs, fmt.Sprintf("--split-count=%d", args.SplitCount))
+	}
+	if args.ChunkSize > 0 {
+		cmdArgs = append(cmdArgs, fmt.Sprintf("--chunk-size=%d", args.ChunkSize))
+	}
+	return cmdArgs, nil
+}
+
+// projectArgs returns the jfrog project flag when set.
+func projectArgs(args Args) []string {
+	if args.Project == "" {
+		return nil
+	}
+	return []string{fmt.Sprintf("--project=%s", args.Project)}
+}
+
+// failNoOpArgs returns the flag that fails the command when no
+// files are affected.
+func failNoOpArgs(args Args) []string {
+	if parseBoolOrDefault(false, args.FailNoOp) {
+		return []string{"--fail-no-op"}
+	}
+	return nil
+}
+
+// confirmArgs returns the flags of a command that deletes remote
+// files. Unless quiet is set to confirm the deletion, the command
+// runs as a dry run and the warning is logged.
+func confirmArgs(args Args, warning string) []string {
+	quiet := parseBoolOrDefault(false, args.Quiet)
+	if quiet && !parseBoolOrDefault(false, args.DryRun) {
+		return []string{"--quiet"}
+	}
+	if !quiet {
+		logrus.Warnln(warning)
+	}
+	return []string{"--dry-run"}
+}
+
+// uploadDryRun returns true if the upload runs as a dry run. Sync
+// deletes removes remote artifacts, so unless quiet is set to confirm
+// it the upload runs as a dry run as well.
+func uploadDryRun(args Args) bool {
+	if parseBoolOrDefault(false, args.DryRun) {
+		return true
+	}
+	return args.SyncDeletes != "" && !parseBoolOrDefault(false, args.Quiet)
+}
+
+// dryRunArgs returns the dry run flag when enabled. The upload
+// uses uploadDryRun instead, which also covers sync deletes.
+func dryRunArgs(args Args) []string {
+	if parseBoolOrDefault(false, args.DryRun) {
+		return []string{"--dry-run"}
+	}
+	return nil
+}
+
+// exclusionsArgs returns the exclusions flag when set. The comma
+// or semicolon separated patterns are combined into the semicolon
+// separated list the jfrog cli expects.
+func exclusionsArgs(args Args) []string {
+	patterns := splitList(args.Exclusions, ",;")
+	if len(patterns) == 0 {
+		return nil
+	}
+	return []string{fmt.Sprintf("--exclusions=%s", strings.Join(patterns, ";"))}
+}
+
+// splitList splits the string on any of the separators, trimming
+// whitespace and dropping empty entries.
+func splitList(s, separators string) []string {
+	fields := strings.FieldsFunc(s, func(r rune) bool {
+		return strings.ContainsRune(separators, r)
+	})
+	var list []string
+	for _, field := range fields {
+		if field = strings.TrimSpace(field); field != "" {
+			list = append(list, field)
+		}
+	}
+	return list
+}
+
+// createOutputFile creates the output file and any missing parent
+// directories.
+func createOutputFile(path string) (*os.File, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("error creating output folder: %s", err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("error creating output file: %s", err)
+	}
+	return f, nil
+}
+
+// writesResults returns true if the command output holds search
+// results that are written to the output file.
+func writesResults(args Args) bool {
+	return args.Command == commandSearch || args.Command == commandAQL
+}
+
+// writeOutput writes the captured command output to the output
+// file.
+func writeOutput(path string, output []byte) error {
+	f, err := createOutputFile(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.Write(output); err != nil {
+		return fmt.Errorf("error writing output file: %s", err)
+	}
+	return nil
+}
+
+// jfrogBin returns the path to the jfrog binary. The plugin setting
+// takes precedence over the JFROG_CLI_PATH environment variable, and
+// both fall back to the default for the platform.
+func jfrogBin(args Args) (string, error) {
+	path := args.JfrogBin
+	if path == "" {
+		path = os.Getenv("JFROG_CLI_PATH")
+	}
+	if path == "" {
+		return getJfrogBin(), nil
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("jfrog binary %q does not exist", path)
+	}
+	if info.IsDir() || (runtime.GOOS != "windows" && info.Mode()&0111 == 0) {
+		return "", fmt.Errorf("jfrog binary %q is not executable", path)
+	}
+	return path, nil
+}
+
+func getJfrogBin() string {
 	if runtime.GOOS == "windows" {
-		return "$Env:"
+		return "C:/bin/jfrog.exe"
 	}
-	return "$"
+	return "jfrog"
 }
 
 func parseBoolOrDefault(defaultValue bool, s string) (result bool) {
@@ -170,8 +1468,142 @@ func parseBoolOrDefault(defaultValue bool, s string) (result bool) {
 	return
 }
 
+// cliLogLevel returns the jfrog cli log level matching the plugin
+// log level, or an empty string to keep the cli default when the
+// level is empty or invalid.
+func cliLogLevel(s string) string {
+	if s == "" {
+		return ""
+	}
+	level, err := logrus.ParseLevel(s)
+	if err != nil {
+		return ""
+	}
+	switch level {
+	case logrus.TraceLevel, logrus.DebugLevel:
+		return "DEBUG"
+	case logrus.InfoLevel:
+		return "INFO"
+	case logrus.WarnLevel:
+		return "WARN"
+	default:
+		return "ERROR"
+	}
+}
+
+// LogLevel returns the logrus level for the plugin log level,
+// falling back to info when the level is empty or invalid.
+func LogLevel(s string) logrus.Level {
+	level, err := logrus.ParseLevel(s)
+	if err != nil {
+		return logrus.InfoLevel
+	}
+	return level
+}
+
+// ExitError reports the exit code of a jfrog command that failed.
+// It wraps the error of the command runner, so the underlying
+// *exec.ExitError can be retrieved with errors.As.
+type ExitError struct {
+	Command string
+	Code    int
+	Err     error
+}
+
+func (e *ExitError) Error() string {
+	return fmt.Sprintf("error running %s: exit code %d", e.Command, e.Code)
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// multiError aggregates the errors of the commands that failed.
+type multiError []error
+
+func (e multiError) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// parseDuration parses a duration string, treating an empty
+// string as zero.
+func parseDuration(s string) (time.Duration, error) {
+	if s == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(s)
+}
+
+// sleep pauses for the given duration or until the context
+// is cancelled, whichever happens first.
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
 // trace writes each command to stdout with the command wrapped in an xml
-// tag so that it can be extracted and displayed in the logs.
-func trace(cmd *exec.Cmd) {
-	fmt.Fprintf(os.Stdout, "+ %s\n", strings.Join(cmd.Args, " "))
+// tag so that it can be extracted and displayed in the logs. Credentials
+// and any occurrence of the secret values are masked.
+func trace(bin string, cmdArgs []string, secrets ...string) {
+	fmt.Fprintf(os.Stdout, "+ %s\n", strings.Join(redact(append([]string{bin}, cmdArgs...), secrets...), " "))
+}
+
+// redact returns a copy of the command arguments with the values
+// of the credential flags and any occurrence of the secret values
+// masked.
+func redact(args []string, secrets ...string) []string {
+	redacted := make([]string, len(args))
+	for i, arg := range args {
+		for _, secret := range secrets {
+			if secret != "" {
+				arg = strings.ReplaceAll(arg, secret, "****")
+			}
+		}
+		for _, flag := range []string{"--password=", "--apikey=", "--access-token=", "--passphrase="} {
+			if strings.HasPrefix(arg, flag) {
+				arg = flag + "****"
+			}
+		}
+		redacted[i] = arg
+	}
+	return redacted
+}
+
+// redactedError is an error with the secret values masked in its
+// message.
+type redactedError struct {
+	msg string
+	err error
+}
+
+func (e *redactedError) Error() string { return e.msg }
+
+func (e *redactedError) Unwrap() error { return e.err }
+
+// redactError returns err with any occurrence of the secret values
+// masked in its message.
+func redactError(err error, secrets ...string) error {
+	if err == nil {
+		return nil
+	}
+	msg := strings.Join(redact([]string{err.Error()}, secrets...), "")
+	if msg == err.Error() {
+		return err
+	}
+	return &redactedError{msg: msg, err: err}
+}
+
+// secrets returns the configured credential values.
+func secrets(args Args) []string {
+	return []string{args.Password, args.APIKey, args.AccessToken, args.IdentityToken, args.GPGPassphrase}
 }
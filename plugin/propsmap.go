@@ -0,0 +1,68 @@
+// Copyright 2020 the Drone Authors. All rights reserved.
+// Use of this source code is governed by the Blue Oak Model License
+// that can be found in the LICENSE file.
+
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// propsPattern is a source pattern and the properties set on the
+// artifacts uploaded from it.
+type propsPattern struct {
+	source string
+	props  string
+}
+
+// parsePropsMap parses the json object mapping each source pattern
+// to its target properties, e.g. {"dist/*.zip": "type=zip;os=linux"}.
+// The patterns are returned in sorted order.
+func parsePropsMap(s string) ([]propsPattern, error) {
+	var m map[string]string
+	if err := json.Unmarshal([]byte(s), &m); err != nil {
+		return nil, fmt.Errorf("invalid props map, must be a json object of source patterns to properties: %s", err)
+	}
+	if len(m) == 0 {
+		return nil, fmt.Errorf("props map has no source patterns")
+	}
+	var patterns []propsPattern
+	for source, props := range m {
+		if strings.TrimSpace(source) == "" {
+			return nil, fmt.Errorf("props map has an empty source pattern")
+		}
+		if strings.TrimSpace(props) == "" {
+			return nil, fmt.Errorf("props map has no properties for %q", source)
+		}
+		patterns = append(patterns, propsPattern{source: source, props: props})
+	}
+	sort.Slice(patterns, func(i, j int) bool {
+		return patterns[i].source < patterns[j].source
+	})
+	return patterns, nil
+}
+
+// propsMapCommands returns one upload per source pattern of the
+// props map, each with its own target properties. The target props
+// apply to every pattern.
+func propsMapCommands(args Args) ([][]string, error) {
+	patterns, err := parsePropsMap(args.PropsMap)
+	if err != nil {
+		return nil, err
+	}
+	var cmds [][]string
+	for _, pattern := range patterns {
+		a := args
+		a.Source = pattern.source
+		a.TargetProps = strings.Join(splitList(args.TargetProps+";"+pattern.props, ";"), ";")
+		cmdArgs, err := buildCommand(a)
+		if err != nil {
+			return nil, err
+		}
+		cmds = append(cmds, cmdArgs)
+	}
+	return cmds, nil
+}
@@ -0,0 +1,76 @@
+// Copyright 2020 the Drone Authors. All rights reserved.
+// Use of this source code is governed by the Blue Oak Model License
+// that can be found in the LICENSE file.
+
+package plugin
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParsePropsMap(t *testing.T) {
+	got, err := parsePropsMap(`{"docs/*.pdf": "type=doc", "dist/*.tar.gz": "type=archive;os=linux"}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []propsPattern{
+		{source: "dist/*.tar.gz", props: "type=archive;os=linux"},
+		{source: "docs/*.pdf", props: "type=doc"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Want patterns %v, got %v", want, got)
+	}
+}
+
+func TestParsePropsMapErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		in   string
+	}{
+		{name: "invalid json", in: `{"dist/*": `},
+		{name: "array", in: `["dist/*"]`},
+		{name: "non string properties", in: `{"dist/*": {"type": "archive"}}`},
+		{name: "empty", in: `{}`},
+		{name: "empty pattern", in: `{"": "type=archive"}`},
+		{name: "empty properties", in: `{"dist/*": ""}`},
+	}
+	for _, test := range tests {
+		if _, err := parsePropsMap(test.in); err == nil {
+			t.Errorf("%s: expect error", test.name)
+		}
+	}
+}
+
+func TestBuildCommandsPropsMap(t *testing.T) {
+	args := Args{
+		URL:         "https://artifactory.example.com",
+		APIKey:      "key",
+		PropsMap:    `{"docs/*.pdf": "type=doc", "dist/*.tar.gz": "type=archive;os=linux"}`,
+		TargetProps: "release=1.0",
+		Target:      "repo/app/",
+	}
+	cmds, err := buildCommands(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, cmdArgs := range cmds {
+		got = append(got, strings.Join(cmdArgs, " "))
+	}
+	want := []string{
+		"rt u --url=https://artifactory.example.com --apikey=key --target-props=release=1.0;type=archive;os=linux dist/*.tar.gz repo/app/",
+		"rt u --url=https://artifactory.example.com --apikey=key --target-props=release=1.0;type=doc docs/*.pdf repo/app/",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Want commands\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(got, "\n"))
+	}
+
+	args.Command = "download"
+	args.TargetProps = ""
+	args.Source = "repo/app/"
+	if _, err := buildCommands(args); err == nil {
+		t.Error("Expect error when props map is set for download")
+	}
+}
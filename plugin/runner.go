@@ -0,0 +1,46 @@
+// Copyright 2020 the Drone Authors. All rights reserved.
+// Use of this source code is governed by the Blue Oak Model License
+// that can be found in the LICENSE file.
+
+package plugin
+
+import (
+	"context"
+	"io"
+	"os/exec"
+)
+
+// CommandRunner runs an external command with the given arguments
+// and environment.
+type CommandRunner interface {
+	Run(ctx context.Context, name string, args []string, env []string) error
+}
+
+// newRunner returns the runner used to run the jfrog commands in
+// dir, writing the command output to stdout and the logs to stderr.
+// An empty dir runs the commands in the current directory. It is a
+// variable so that tests can replace it.
+var newRunner = newExecRunner
+
+// execRunner runs commands with os/exec.
+type execRunner struct {
+	dir    string
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// newExecRunner returns a runner that runs the commands in dir and
+// writes the command output to stdout and the logs to stderr.
+func newExecRunner(dir string, stdout, stderr io.Writer) CommandRunner {
+	return &execRunner{dir: dir, stdout: stdout, stderr: stderr}
+}
+
+// Run runs the command and waits for it to complete.
+func (r *execRunner) Run(ctx context.Context, name string, args []string, env []string) error {
+	cmd := exec.CommandContext(ctx, name, args...)
+	cmd.Env = env
+	cmd.Dir = r.dir
+	cmd.Stdout = r.stdout
+	cmd.Stderr = r.stderr
+	return cmd.Run()
+}
@@ -0,0 +1,103 @@
+// Copyright 2020 the Drone Authors. All rights reserved.
+// Use of this source code is governed by the Blue Oak Model License
+// that can be found in the LICENSE file.
+
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"reflect"
+	"runtime"
+	"testing"
+)
+
+// fakeRunner records the commands instead of running them.
+type fakeRunner struct {
+	calls  []fakeCall
+	dir    string
+	stdout io.Writer
+	stderr io.Writer
+
+	// run is called for each command, when set, and returns the
+	// command error.
+	run func(call fakeCall) error
+}
+
+// fakeCall provides the details of a recorded command.
+type fakeCall struct {
+	name   string
+	args   []string
+	env    []string
+	dir    string
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// useFakeRunner replaces the command runner with a fake for the
+// duration of the test.
+func useFakeRunner(t *testing.T, run func(call fakeCall) error) *fakeRunner {
+	r := &fakeRunner{run: run}
+	newRunner = func(dir string, stdout, stderr io.Writer) CommandRunner {
+		r.dir = dir
+		r.stdout = stdout
+		r.stderr = stderr
+		return r
+	}
+	t.Cleanup(func() { newRunner = newExecRunner })
+	return r
+}
+
+func (r *fakeRunner) Run(ctx context.Context, name string, args []string, env []string) error {
+	call := fakeCall{name: name, args: args, env: env, dir: r.dir, stdout: r.stdout, stderr: r.stderr}
+	r.calls = append(r.calls, call)
+	if r.run != nil {
+		return r.run(call)
+	}
+	return nil
+}
+
+func TestFakeRunner(t *testing.T) {
+	runner := useFakeRunner(t, nil)
+
+	args := Args{
+		URL:     "https://artifactory.example.com",
+		APIKey:  "key",
+		Command: "search",
+		Target:  "repo/app/*.zip",
+	}
+	if err := Exec(context.Background(), args); err != nil {
+		t.Fatal(err)
+	}
+	if len(runner.calls) != 1 {
+		t.Fatalf("Want a single command, got %d", len(runner.calls))
+	}
+	call := runner.calls[0]
+	if call.name != getJfrogBin() {
+		t.Errorf("Want command %q, got %q", getJfrogBin(), call.name)
+	}
+	want := []string{"rt", "s", "--url=https://artifactory.example.com", "--apikey=key", "repo/app/*.zip"}
+	if !reflect.DeepEqual(call.args, want) {
+		t.Errorf("Want arguments %q, got %q", want, call.args)
+	}
+	if !contains(call.env, "JFROG_CLI_OFFER_CONFIG=false") {
+		t.Error("Want JFROG_CLI_OFFER_CONFIG=false in the command environment")
+	}
+}
+
+func TestExecRunner(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("exec runner test requires a posix shell")
+	}
+	var stdout bytes.Buffer
+	dir := t.TempDir()
+	runner := newExecRunner(dir, &stdout, io.Discard)
+	err := runner.Run(context.Background(), "sh", []string{"-c", "echo $GREETING; pwd"}, []string{"GREETING=hello"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := stdout.String(), "hello\n"+dir+"\n"; got != want {
+		t.Errorf("Want output %q, got %q", want, got)
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2020 the Drone Authors. All rights reserved.
+// Use of this source code is governed by the Blue Oak Model License
+// that can be found in the LICENSE file.
+
+package plugin
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	"github.com/sirupsen/logrus"
+)
+
+// scanResult provides the xray scan results of a file.
+type scanResult struct {
+	Violations      []json.RawMessage `json:"violations"`
+	Vulnerabilities []json.RawMessage `json:"vulnerabilities"`
+}
+
+// scanCommands returns the commands used to configure the server
+// and scan the local files with jfrog scan. The server is configured
+// with the platform url, which the xray url is derived from. The scan
+// itself never fails, the results are checked against the threshold
+// instead.
+func scanCommands(args Args) ([][]string, error) {
+	if args.Source == "" {
+		return nil, fmt.Errorf("source needs to be set")
+	}
+	if args.PlatformURL == "" {
+		return nil, fmt.Errorf("platform url needs to be set")
+	}
+	configArgs, err := configCommand(args)
+	if err != nil {
+		return nil, err
+	}
+	cmdArgs := []string{"scan", fmt.Sprintf("--server-id=%s", serverID(args)), "--format=json", "--fail=false"}
+	if args.Watches != "" {
+		cmdArgs = append(cmdArgs, fmt.Sprintf("--watches=%s", args.Watches))
+	}
+	cmdArgs = append(cmdArgs, projectArgs(args)...)
+	return [][]string{configArgs, append(cmdArgs, args.Source)}, nil
+}
+
+// buildScanCommand returns the jfrog rt bs arguments used to scan
+// the published build with xray. The command fails when the build
+// violates a policy with fail build set, unless scan fail is false.
+func buildScanCommand(args Args) ([]string, error) {
+	cmdArgs, err := baseCommand(args, "rt", "bs")
+	if err != nil {
+		return nil, err
+	}
+	name, number, err := requireBuild(args)
+	if err != nil {
+		return nil, err
+	}
+	cmdArgs = append(cmdArgs, fmt.Sprintf("--fail=%t", parseBoolOrDefault(true, args.ScanFail)))
+	cmdArgs = append(cmdArgs, projectArgs(args)...)
+	return append(cmdArgs, name, number), nil
+}
+
+// isScan returns true if the command is the xray scan, whose
+// results are checked.
+func isScan(args Args, cmdArgs []string) bool {
+	return args.Command == commandScan && len(cmdArgs) > 0 && cmdArgs[0] == "scan"
+}
+
+// parseScan parses the json scan results printed by jfrog scan.
+// Any output before the results is ignored.
+func parseScan(output []byte) ([]scanResult, error) {
+	i := bytes.IndexByte(output, '[')
+	if i == -1 {
+		return nil, fmt.Errorf("no scan results found in the command output")
+	}
+	var results []scanResult
+	if err := json.NewDecoder(bytes.NewReader(output[i:])).Decode(&results); err != nil {
+		return nil, fmt.Errorf("error parsing scan results: %s", err)
+	}
+	return results, nil
+}
+
+// checkScan logs the scan totals and returns an error if the scan
+// fails and found more issues than allowed. The violations of the
+// watches are counted when watches are set, the vulnerabilities
+// otherwise.
+func checkScan(args Args, results []scanResult) error {
+	var violations, vulnerabilities int
+	for _, result := range results {
+		violations += len(result.Violations)
+		vulnerabilities += len(result.Vulnerabilities)
+	}
+	logrus.Infof("Scan found %d violations and %d vulnerabilities", violations, vulnerabilities)
+
+	found, kind := vulnerabilities, "vulnerabilities"
+	if args.Watches != "" {
+		found, kind = violations, "violations"
+	}
+	if parseBoolOrDefault(true, args.ScanFail) && found > args.MaxViolations {
+		return fmt.Errorf("scan found %d %s, more than the %d allowed", found, kind, args.MaxViolations)
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+// Copyright 2020 the Drone Authors. All rights reserved.
+// Use of this source code is governed by the Blue Oak Model License
+// that can be found in the LICENSE file.
+
+package plugin
+
+import (
+	"context"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestScanCommands(t *testing.T) {
+	args := Args{
+		Command:     "scan",
+		URL:         "https://artifactory.example.com/artifactory",
+		PlatformURL: "https://artifactory.example.com",
+		APIKey:      "key",
+		Source:      "dist/*.tgz",
+		Watches:     "security-watch,license-watch",
+	}
+	cmds, err := buildCommands(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cmds) != 2 {
+		t.Fatalf("Want config and scan commands, got %q", cmds)
+	}
+	want := []string{"scan", "--server-id=drone", "--format=json", "--fail=false", "--watches=security-watch,license-watch", "dist/*.tgz"}
+	if !reflect.DeepEqual(cmds[1], want) {
+		t.Errorf("Want command %q, got %q", want, cmds[1])
+	}
+	want = []string{"config", "add", "drone", "--artifactory-url=https://artifactory.example.com/artifactory", "--url=https://artifactory.example.com", "--apikey=key", "--interactive=false", "--overwrite=true"}
+	if !reflect.DeepEqual(cmds[0], want) {
+		t.Errorf("Want config command %q, got %q", want, cmds[0])
+	}
+	platformURL := args.PlatformURL
+	args.PlatformURL = ""
+	if _, err := buildCommands(args); err == nil {
+		t.Error("Expect error when platform url is missing")
+	}
+	args.PlatformURL = platformURL
+	args.Source = ""
+	if _, err := buildCommands(args); err == nil {
+		t.Error("Expect error when source is missing")
+	}
+}
+
+func TestCheckScan(t *testing.T) {
+	output, err := os.ReadFile("testdata/scan.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := parseScan(append([]byte("Scanning 1 file\n"), output...))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		args Args
+		fail bool
+	}{
+		{name: "vulnerabilities", args: Args{}, fail: true},
+		{name: "vulnerabilities allowed", args: Args{MaxViolations: 2}},
+		{name: "violations", args: Args{Watches: "security-watch", MaxViolations: 0}, fail: true},
+		{name: "violations allowed", args: Args{Watches: "security-watch", MaxViolations: 1}},
+		{name: "no fail", args: Args{ScanFail: "false"}},
+	}
+	for _, test := range tests {
+		err := checkScan(test.args, results)
+		if test.fail && err == nil {
+			t.Errorf("%s: expect the scan to fail", test.name)
+		}
+		if !test.fail && err != nil {
+			t.Errorf("%s: unexpected error: %s", test.name, err)
+		}
+	}
+}
+
+func TestExecScan(t *testing.T) {
+	output, err := os.ReadFile("testdata/scan.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	useFakeRunner(t, func(call fakeCall) error {
+		if call.args[0] == "scan" {
+			_, err := call.stdout.Write(output)
+			return err
+		}
+		return nil
+	})
+
+	args := Args{
+		Command:     "scan",
+		URL:         "https://artifactory.example.com/artifactory",
+		PlatformURL: "https://artifactory.example.com",
+		APIKey:      "key",
+		Source:      "dist/*.tgz",
+	}
+	if err := Exec(context.Background(), args); err == nil {
+		t.Error("Expect error when the scan finds vulnerabilities")
+	}
+	args.MaxViolations = 5
+	if err := Exec(context.Background(), args); err != nil {
+		t.Errorf("Expect success below the threshold, got %s", err)
+	}
+}
+
+func TestBuildScanCommand(t *testing.T) {
+	tests := []struct {
+		fail string
+		want string
+	}{
+		{fail: "", want: `rt bs --url=https://artifactory.example.com --apikey=key --fail=true app 42`},
+		{fail: "false", want: `rt bs --url=https://artifactory.example.com --apikey=key --fail=false app 42`},
+	}
+	for _, test := range tests {
+		args := Args{
+			Command:     "build-scan",
+			URL:         "https://artifactory.example.com",
+			APIKey:      "key",
+			BuildName:   "app",
+			BuildNumber: "42",
+			ScanFail:    test.fail,
+		}
+		cmdArgs, err := buildCommand(args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := strings.Join(cmdArgs, " "); got != test.want {
+			t.Errorf("Want command\n%s\ngot\n%s", test.want, got)
+		}
+	}
+}
+
+func TestBuildScanCommandErrors(t *testing.T) {
+	args := Args{
+		Command: "build-scan",
+		URL:     "https://artifactory.example.com",
+		APIKey:  "key",
+	}
+	if _, err := buildCommand(args); err == nil {
+		t.Error("Expect error when build name is missing")
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright 2020 the Drone Authors. All rights reserved.
+// Use of this source code is governed by the Blue Oak Model License
+// that can be found in the LICENSE file.
+
+package plugin
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// specArgs returns the file spec flags.
+func specArgs(args Args) ([]string, error) {
+	if err := checkSpecFile(resolvePath(args, args.Spec)); err != nil {
+		return nil, err
+	}
+	cmdArgs := []string{fmt.Sprintf("--spec=%s", args.Spec)}
+	vars, err := specVars(args)
+	if err != nil {
+		return nil, err
+	}
+	if vars != "" {
+		cmdArgs = append(cmdArgs, fmt.Sprintf("--spec-vars=%s", vars))
+	}
+	return cmdArgs, nil
+}
+
+// mergeSpecs returns a single spec with the files of every spec
+// file, in order.
+func mergeSpecs(paths []string) ([]byte, error) {
+	var merged struct {
+		Files []json.RawMessage `json:"files"`
+	}
+	merged.Files = []json.RawMessage{}
+	for _, path := range paths {
+		if err := checkSpecFile(path); err != nil {
+			return nil, err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading spec file %q: %s", path, err)
+		}
+		var spec struct {
+			Files []json.RawMessage `json:"files"`
+		}
+		if err := json.Unmarshal(data, &spec); err != nil {
+			return nil, fmt.Errorf("error parsing spec file %q: %s", path, err)
+		}
+		merged.Files = append(merged.Files, spec.Files...)
+	}
+	return json.MarshalIndent(merged, "", "  ")
+}
+
+// writeMergedSpec merges the spec files into a temporary spec file
+// and returns its path.
+func writeMergedSpec(paths []string) (string, error) {
+	data, err := mergeSpecs(paths)
+	if err != nil {
+		return "", err
+	}
+	f, err := os.CreateTemp("", "spec-*.json")
+	if err != nil {
+		return "", fmt.Errorf("error creating merged spec file: %s", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(data); err != nil {
+		os.Remove(f.Name())
+		return "", fmt.Errorf("error writing merged spec file: %s", err)
+	}
+	return f.Name(), nil
+}
+
+// checkSpecFile returns an error if the spec file does not exist
+// or cannot be read.
+func checkSpecFile(path string) error {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("spec file %q does not exist", path)
+	}
+	if err != nil {
+		return fmt.Errorf("error reading spec file %q: %s", path, err)
+	}
+	if info.IsDir() {
+		return fmt.Errorf("spec file %q is a directory", path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("spec file %q is not readable: %s", path, err)
+	}
+	return f.Close()
+}
+
+// specVars returns the semicolon separated spec variables. The
+// inline variables are merged with the variables read from the
+// spec vars file, with the file taking precedence.
+func specVars(args Args) (string, error) {
+	vars := parseSpecVars(args.SpecVars)
+	if args.SpecVarsFile != "" {
+		data, err := os.ReadFile(resolvePath(args, args.SpecVarsFile))
+		if err != nil {
+			return "", fmt.Errorf("error reading spec vars file: %s", err)
+		}
+		fileVars, err := parseSpecVarsFile(data)
+		if err != nil {
+			return "", err
+		}
+		vars = mergeSpecVars(vars, fileVars)
+	}
+	pairs := make([]string, len(vars))
+	for i, v := range vars {
+		pairs[i] = v.key + "=" + v.value
+	}
+	return strings.Join(pairs, ";"), nil
+}
+
+// specVar is a single spec variable.
+type specVar struct {
+	key   string
+	value string
+}
+
+// parseSpecVars parses the inline key=value;key2=value2 format.
+func parseSpecVars(s string) []specVar {
+	var vars []specVar
+	for _, pair := range splitList(s, ";") {
+		key, value, _ := strings.Cut(pair, "=")
+		vars = append(vars, specVar{key: strings.TrimSpace(key), value: value})
+	}
+	return vars
+}
+
+// parseSpecVarsFile parses a file with one key=value pair per line.
+// Blank lines and lines starting with # are ignored.
+func parseSpecVarsFile(data []byte) ([]specVar, error) {
+	var vars []specVar
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			return nil, fmt.Errorf("invalid spec var on line %d, expected key=value", n)
+		}
+		vars = append(vars, specVar{key: strings.TrimSpace(key), value: strings.TrimSpace(value)})
+	}
+	return vars, scanner.Err()
+}
+
+// mergeSpecVars merges the overrides into the variables, replacing
+// the value of existing keys and appending new keys in order.
+func mergeSpecVars(vars, overrides []specVar) []specVar {
+	merged := append([]specVar{}, vars...)
+	for _, override := range overrides {
+		replaced := false
+		for i := range merged {
+			if merged[i].key == override.key {
+				merged[i].value = override.value
+				replaced = true
+			}
+		}
+		if !replaced {
+			merged = append(merged, override)
+		}
+	}
+	return merged
+}
@@ -0,0 +1,172 @@
+// Copyright 2020 the Drone Authors. All rights reserved.
+// Use of this source code is governed by the Blue Oak Model License
+// that can be found in the LICENSE file.
+
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestParseSpecVarsFile(t *testing.T) {
+	data := []byte("# release variables\nversion=1.0.0\n\n  repo = libs-release \nurl=https://example.com/?a=b\n")
+	got, err := parseSpecVarsFile(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []specVar{
+		{key: "version", value: "1.0.0"},
+		{key: "repo", value: "libs-release"},
+		{key: "url", value: "https://example.com/?a=b"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Want spec vars %v, got %v", want, got)
+	}
+
+	if _, err := parseSpecVarsFile([]byte("version\n")); err == nil {
+		t.Error("Expect error for a line without a value")
+	}
+}
+
+func TestSpecVarsMerge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "spec.vars")
+	if err := os.WriteFile(path, []byte("version=2.0.0\nrepo=libs-release\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	args := Args{
+		SpecVars:     "version=1.0.0;name=app",
+		SpecVarsFile: path,
+	}
+	got, err := specVars(args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "version=2.0.0;name=app;repo=libs-release"; got != want {
+		t.Errorf("Want spec vars %s, got %s", want, got)
+	}
+}
+
+func TestSpecVarsMissingFile(t *testing.T) {
+	args := Args{SpecVarsFile: filepath.Join(t.TempDir(), "missing.vars")}
+	if _, err := specVars(args); err == nil {
+		t.Error("Expect error for a missing spec vars file")
+	}
+}
+
+func TestSpecArgs(t *testing.T) {
+	got, err := specArgs(Args{Spec: "testdata/spec.json", SpecVars: "a=b;c=d"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{"--spec=testdata/spec.json", "--spec-vars=a=b;c=d"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Want spec args %q, got %q", want, got)
+	}
+
+	got, err = specArgs(Args{Spec: "testdata/spec.json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"--spec=testdata/spec.json"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("Want spec args %q, got %q", want, got)
+	}
+}
+
+func TestCheckSpecFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := checkSpecFile("testdata/spec.json"); err != nil {
+		t.Errorf("Unexpected error for an existing spec file: %s", err)
+	}
+	if err := checkSpecFile(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("Expect error for a missing spec file")
+	}
+	if err := checkSpecFile(dir); err == nil {
+		t.Error("Expect error when the spec file is a directory")
+	}
+}
+
+func TestCheckSpecFileUnreadable(t *testing.T) {
+	if runtime.GOOS == "windows" || os.Geteuid() == 0 {
+		t.Skip("file permissions are not enforced")
+	}
+	path := filepath.Join(t.TempDir(), "spec.json")
+	if err := os.WriteFile(path, []byte(`{"files": []}`), 0000); err != nil {
+		t.Fatal(err)
+	}
+	if err := checkSpecFile(path); err == nil {
+		t.Error("Expect error for an unreadable spec file")
+	}
+}
+
+func TestMergeSpecs(t *testing.T) {
+	data, err := mergeSpecs([]string{"testdata/spec.json", "testdata/spec-docs.json"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got struct {
+		Files []map[string]string `json:"files"`
+	}
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := []map[string]string{
+		{"pattern": "dist/*.tar.gz", "target": "repo/app/${version}/"},
+		{"pattern": "docs/*.pdf", "target": "repo/docs/${version}/"},
+	}
+	if !reflect.DeepEqual(got.Files, want) {
+		t.Errorf("Want merged files %v, got %v", want, got.Files)
+	}
+}
+
+func TestMergeSpecsErrors(t *testing.T) {
+	malformed := filepath.Join(t.TempDir(), "malformed.json")
+	if err := os.WriteFile(malformed, []byte(`{"files": [`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mergeSpecs([]string{"testdata/spec.json", malformed}); err == nil || !strings.Contains(err.Error(), "error parsing spec file") {
+		t.Errorf("Expect error for a malformed spec, got %v", err)
+	}
+	if _, err := mergeSpecs([]string{"testdata/spec.json", "testdata/missing.json"}); err == nil || !strings.Contains(err.Error(), "does not exist") {
+		t.Errorf("Expect error for a missing spec, got %v", err)
+	}
+}
+
+func TestExecMergedSpec(t *testing.T) {
+	var spec string
+	var data []byte
+	runner := useFakeRunner(t, func(call fakeCall) error {
+		for _, arg := range call.args {
+			if strings.HasPrefix(arg, "--spec=") {
+				spec = strings.TrimPrefix(arg, "--spec=")
+			}
+		}
+		var err error
+		data, err = os.ReadFile(spec)
+		return err
+	})
+
+	args := Args{
+		URL:    "https://artifactory.example.com",
+		APIKey: "key",
+		Spec:   "testdata/spec.json,testdata/spec-docs.json",
+	}
+	if err := Exec(context.Background(), args); err != nil {
+		t.Fatal(err)
+	}
+	if len(runner.calls) != 1 {
+		t.Fatalf("Want a single upload for the merged spec, got %d", len(runner.calls))
+	}
+	if !strings.Contains(string(data), "docs/*.pdf") || !strings.Contains(string(data), "dist/*.tar.gz") {
+		t.Errorf("Want the merged spec passed to the cli, got %s", data)
+	}
+	if _, err := os.Stat(spec); !os.IsNotExist(err) {
+		t.Errorf("Want the merged spec %s removed after running", spec)
+	}
+}
@@ -0,0 +1,152 @@
+// Copyright 2020 the Drone Authors. All rights reserved.
+// Use of this source code is governed by the Blue Oak Model License
+// that can be found in the LICENSE file.
+
+package plugin
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Summary provides the detailed summary printed by the jfrog cli
+// for upload and download commands.
+type Summary struct {
+	Status string `json:"status"`
+	Totals struct {
+		Success int `json:"success"`
+		Failure int `json:"failure"`
+	} `json:"totals"`
+	Files []SummaryFile `json:"files"`
+}
+
+// SummaryFile provides the details of a transferred artifact.
+type SummaryFile struct {
+	Source string `json:"source"`
+	Target string `json:"target"`
+	SHA256 string `json:"sha256"`
+}
+
+// merge adds the totals and files of the other summary.
+func (s *Summary) merge(other *Summary) {
+	if s.Status == "" || other.Status != "success" {
+		s.Status = other.Status
+	}
+	s.Totals.Success += other.Totals.Success
+	s.Totals.Failure += other.Totals.Failure
+	s.Files = append(s.Files, other.Files...)
+}
+
+// reportSummary logs the summary totals and writes the summary to
+// the output file when set.
+func reportSummary(args Args, summary *Summary) error {
+	logrus.Infof("Transferred %d artifacts, %d failed", summary.Totals.Success, summary.Totals.Failure)
+	if args.OutputFile != "" {
+		return writeSummary(args.OutputFile, summary)
+	}
+	return nil
+}
+
+// detailedSummary returns true if the detailed summary is enabled
+// for a command that supports it.
+func detailedSummary(args Args) bool {
+	switch args.Command {
+	case "", commandUpload, commandDownload:
+		return parseBoolOrDefault(false, args.DetailedSummary) || verifyChecksum(args)
+	default:
+		return false
+	}
+}
+
+// verifyChecksum returns true if the checksums of the uploaded
+// files are verified. The detailed summary provides the remote
+// checksums, so it is enabled as well.
+func verifyChecksum(args Args) bool {
+	return isUpload(args) && parseBoolOrDefault(false, args.VerifyChecksum)
+}
+
+// verifyChecksums compares the sha256 checksum reported for each
+// uploaded file with the checksum of the local file. Relative
+// source paths are resolved against dir.
+func verifyChecksums(dir string, files []SummaryFile) error {
+	var mismatched []string
+	for _, file := range files {
+		path := file.Source
+		if !filepath.IsAbs(path) && dir != "" {
+			path = filepath.Join(dir, path)
+		}
+		sum, err := sha256File(path)
+		if err != nil {
+			return err
+		}
+		if !strings.EqualFold(sum, file.SHA256) {
+			logrus.Errorf("Checksum mismatch for %s: local %s, remote %s", file.Source, sum, file.SHA256)
+			mismatched = append(mismatched, file.Source)
+		}
+	}
+	if len(mismatched) != 0 {
+		return fmt.Errorf("checksum mismatch for %s", strings.Join(mismatched, ", "))
+	}
+	logrus.Infof("Verified the checksums of %d artifacts", len(files))
+	return nil
+}
+
+// sha256File returns the hex encoded sha256 checksum of the file.
+func sha256File(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("error opening %s to verify its checksum: %s", path, err)
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", fmt.Errorf("error reading %s to verify its checksum: %s", path, err)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// summaryArgs returns the detailed summary flag when enabled.
+func summaryArgs(args Args) []string {
+	if detailedSummary(args) {
+		return []string{"--detailed-summary"}
+	}
+	return nil
+}
+
+// parseSummary parses the detailed summary from the command output.
+// Any output before the json document is ignored.
+func parseSummary(output []byte) (*Summary, error) {
+	i := bytes.IndexByte(output, '{')
+	if i == -1 {
+		return nil, fmt.Errorf("no detailed summary found in the command output")
+	}
+	summary := new(Summary)
+	if err := json.NewDecoder(bytes.NewReader(output[i:])).Decode(summary); err != nil {
+		return nil, fmt.Errorf("error parsing detailed summary: %s", err)
+	}
+	return summary, nil
+}
+
+// writeSummary writes the summary as json to the output file.
+func writeSummary(path string, summary *Summary) error {
+	f, err := createOutputFile(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(summary); err != nil {
+		return fmt.Errorf("error writing summary: %s", err)
+	}
+	return nil
+}
@@ -0,0 +1,237 @@
+// Copyright 2020 the Drone Authors. All rights reserved.
+// Use of this source code is governed by the Blue Oak Model License
+// that can be found in the LICENSE file.
+
+package plugin
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var testSummary = []byte(`{
+  "status": "success",
+  "totals": {
+    "success": 2,
+    "failure": 0
+  },
+  "files": [
+    {
+      "source": "dist/app-linux.tar.gz",
+      "target": "https://artifactory.example.com/artifactory/repo/app/app-linux.tar.gz",
+      "sha256": "3b8c7e6a1f1f7c4c2e5b0c1d8a7f3e9d2b4a6c8e0f1a3b5d7c9e1f2a4b6c8d0e"
+    },
+    {
+      "source": "dist/app-windows.zip",
+      "target": "https://artifactory.example.com/artifactory/repo/app/app-windows.zip",
+      "sha256": "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+    }
+  ]
+}
+`)
+
+func TestParseSummary(t *testing.T) {
+	output := append([]byte("[Info] Uploading artifacts...\n"), testSummary...)
+	summary, err := parseSummary(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := summary.Status, "success"; got != want {
+		t.Errorf("Want status %s, got %s", want, got)
+	}
+	if got, want := summary.Totals.Success, 2; got != want {
+		t.Errorf("Want %d successful artifacts, got %d", want, got)
+	}
+	if got, want := len(summary.Files), 2; got != want {
+		t.Fatalf("Want %d files, got %d", want, got)
+	}
+	if got, want := summary.Files[1].SHA256, "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"; got != want {
+		t.Errorf("Want sha256 %s, got %s", want, got)
+	}
+	if got, want := summary.Files[0].Source, "dist/app-linux.tar.gz"; got != want {
+		t.Errorf("Want source %s, got %s", want, got)
+	}
+}
+
+func TestParseSummaryErrors(t *testing.T) {
+	if _, err := parseSummary([]byte("no summary here")); err == nil {
+		t.Error("Expect error when the output has no summary")
+	}
+	if _, err := parseSummary([]byte(`{"status": `)); err == nil {
+		t.Error("Expect error for a truncated summary")
+	}
+}
+
+func TestWriteSummary(t *testing.T) {
+	summary, err := parseSummary(testSummary)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "summary.json")
+	if err := writeSummary(path, summary); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	written, err := parseSummary(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(written.Files), 2; got != want {
+		t.Errorf("Want %d files in the written summary, got %d", want, got)
+	}
+}
+
+func TestSummaryArgs(t *testing.T) {
+	tests := []struct {
+		command string
+		want    bool
+	}{
+		{command: "", want: true},
+		{command: "upload", want: true},
+		{command: "download", want: true},
+		{command: "copy", want: false},
+	}
+	for _, test := range tests {
+		args := Args{Command: test.command, DetailedSummary: "true"}
+		if got := len(summaryArgs(args)) == 1; got != test.want {
+			t.Errorf("%q: want detailed summary flag %v, got %v", test.command, test.want, got)
+		}
+	}
+}
+
+// writeTestArtifacts writes the local files of the test summary to
+// dir. The windows archive matches its checksum, the linux archive
+// does not.
+func writeTestArtifacts(t *testing.T, dir string) {
+	if err := os.MkdirAll(filepath.Join(dir, "dist"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"dist/app-linux.tar.gz": "linux",
+		"dist/app-windows.zip":  "test",
+	}
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestVerifyChecksums(t *testing.T) {
+	dir := t.TempDir()
+	writeTestArtifacts(t, dir)
+	summary, err := parseSummary(testSummary)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := verifyChecksums(dir, summary.Files[1:]); err != nil {
+		t.Errorf("Expect matching checksums, got %s", err)
+	}
+	err = verifyChecksums(dir, summary.Files)
+	if err == nil {
+		t.Fatal("Expect error for a mismatched checksum")
+	}
+	if !strings.Contains(err.Error(), "dist/app-linux.tar.gz") || strings.Contains(err.Error(), "dist/app-windows.zip") {
+		t.Errorf("Expect error to name only the mismatched file, got %q", err)
+	}
+	if err := verifyChecksums(t.TempDir(), summary.Files); err == nil {
+		t.Error("Expect error when the local file is missing")
+	}
+}
+
+func TestExecVerifyChecksum(t *testing.T) {
+	dir := t.TempDir()
+	writeTestArtifacts(t, dir)
+
+	var got []string
+	useFakeRunner(t, func(call fakeCall) error {
+		got = call.args
+		_, err := call.stdout.Write(testSummary)
+		return err
+	})
+
+	args := Args{
+		URL:            "https://artifactory.example.com",
+		APIKey:         "key",
+		Source:         "dist/*",
+		Target:         "repo/app/",
+		WorkingDir:     dir,
+		VerifyChecksum: "true",
+	}
+	err := Exec(context.Background(), args)
+	if err == nil || !strings.Contains(err.Error(), "checksum mismatch") {
+		t.Errorf("Expect checksum mismatch error, got %v", err)
+	}
+	if !contains(got, "--detailed-summary") {
+		t.Errorf("Want the detailed summary enabled to verify checksums, got %q", got)
+	}
+}
+
+func TestExecWithResult(t *testing.T) {
+	useFakeRunner(t, func(call fakeCall) error {
+		_, err := call.stdout.Write(testSummary)
+		return err
+	})
+
+	args := Args{
+		URL:             "https://artifactory.example.com",
+		APIKey:          "key",
+		Source:          "dist/*",
+		Target:          "repo/app/",
+		DetailedSummary: "true",
+	}
+	result, err := ExecWithResult(context.Background(), args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Operation != "upload" {
+		t.Errorf("Want operation upload, got %q", result.Operation)
+	}
+	if result.FilesAffected != 2 {
+		t.Errorf("Want 2 files affected, got %d", result.FilesAffected)
+	}
+	if result.Summary == nil || len(result.Summary.Files) != 2 {
+		t.Errorf("Want the summary with 2 files, got %+v", result.Summary)
+	}
+	if result.RawOutput != string(testSummary) {
+		t.Errorf("Want raw output %q, got %q", testSummary, result.RawOutput)
+	}
+	if result.Duration <= 0 {
+		t.Errorf("Want the run duration, got %s", result.Duration)
+	}
+}
+
+func TestExecWithResultNoSummary(t *testing.T) {
+	output := "[Info] Searching artifacts...\n[]\n"
+	useFakeRunner(t, func(call fakeCall) error {
+		_, err := call.stdout.Write([]byte(output))
+		return err
+	})
+
+	args := Args{
+		URL:     "https://artifactory.example.com",
+		APIKey:  "key",
+		Command: "search",
+		Target:  "repo/app/*.zip",
+	}
+	result, err := ExecWithResult(context.Background(), args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Operation != "search" {
+		t.Errorf("Want operation search, got %q", result.Operation)
+	}
+	if result.FilesAffected != 0 || result.Summary != nil {
+		t.Errorf("Want no summary without the detailed summary, got %d files and %+v", result.FilesAffected, result.Summary)
+	}
+	if result.RawOutput != output {
+		t.Errorf("Want raw output %q, got %q", output, result.RawOutput)
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2020 the Drone Authors. All rights reserved.
+// Use of this source code is governed by the Blue Oak Model License
+// that can be found in the LICENSE file.
+
+package plugin
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/sirupsen/logrus"
+)
+
+// dryRunDelete is logged by the jfrog cli for each artifact that
+// a dry run would delete.
+const dryRunDelete = "[Dry run] Deleting"
+
+// syncDeletesThreshold returns true if the upload deletes remote
+// artifacts and the number of deletions needs to be checked first.
+func syncDeletesThreshold(args Args) bool {
+	return isUpload(args) &&
+		args.SyncDeletes != "" &&
+		args.SyncDeletesThreshold > 0 &&
+		!uploadDryRun(args)
+}
+
+// checkSyncDeletes runs the upload as a dry run and returns an error
+// if sync deletes would delete more artifacts than the threshold.
+// The dry run fails if it prints no summary, since the deletions
+// cannot be counted from an incomplete run.
+func checkSyncDeletes(ctx context.Context, args Args, bin string) error {
+	dryRun := args
+	dryRun.DryRun = "true"
+	// The deletions are logged at the info level, so a warn or error
+	// level would hide them from the count.
+	if cliLogLevel(dryRun.Level) != "DEBUG" {
+		dryRun.Level = "info"
+	}
+	cmds, err := buildCommands(dryRun)
+	if err != nil {
+		return err
+	}
+	var deletes int
+	for _, cmdArgs := range cmds {
+		var output, logs bytes.Buffer
+		if err := run(ctx, dryRun, bin, cmdArgs, io.MultiWriter(os.Stdout, &output), io.MultiWriter(os.Stderr, &logs)); err != nil {
+			return fmt.Errorf("error running the sync deletes dry run: %s", err)
+		}
+		if isConfig(cmdArgs) {
+			continue
+		}
+		if _, err := parseSummary(output.Bytes()); err != nil {
+			return fmt.Errorf("error reading the sync deletes dry run: %s", err)
+		}
+		deletes += countSyncDeletes(logs.Bytes())
+	}
+	if deletes > args.SyncDeletesThreshold {
+		return fmt.Errorf("sync deletes would delete %d artifacts, more than the threshold of %d", deletes, args.SyncDeletesThreshold)
+	}
+	logrus.Infof("Sync deletes will delete %d artifacts", deletes)
+	return nil
+}
+
+// countSyncDeletes returns the number of deletions logged by a dry
+// run.
+func countSyncDeletes(logs []byte) int {
+	var n int
+	scanner := bufio.NewScanner(bytes.NewReader(logs))
+	for scanner.Scan() {
+		if strings.Contains(scanner.Text(), dryRunDelete) {
+			n++
+		}
+	}
+	return n
+}
@@ -0,0 +1,149 @@
+// Copyright 2020 the Drone Authors. All rights reserved.
+// Use of this source code is governed by the Blue Oak Model License
+// that can be found in the LICENSE file.
+
+package plugin
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+)
+
+const testDryRunLogs = `[Info] [Dry run] Uploading artifact: dist/app.zip
+[Info] [Dry run] Deleting repo/app/old-1.zip
+[Info] [Dry run] Deleting repo/app/old-2.zip
+[Info] [Dry run] Deleting repo/app/old-3.zip
+`
+
+const testDryRunSummary = `{"status": "success", "totals": {"success": 1, "failure": 0}}`
+
+func TestCountSyncDeletes(t *testing.T) {
+	if got := countSyncDeletes([]byte(testDryRunLogs)); got != 3 {
+		t.Errorf("Want 3 deletions, got %d", got)
+	}
+	if got := countSyncDeletes(nil); got != 0 {
+		t.Errorf("Want no deletions for empty logs, got %d", got)
+	}
+}
+
+func TestExecSyncDeletesThreshold(t *testing.T) {
+	tests := []struct {
+		threshold int
+		calls     int
+		err       bool
+	}{
+		{threshold: 2, calls: 1, err: true},
+		{threshold: 3, calls: 2},
+	}
+	for _, test := range tests {
+		runner := useFakeRunner(t, func(call fakeCall) error {
+			if contains(call.args, "--dry-run") {
+				io.WriteString(call.stdout, testDryRunSummary)
+				_, err := io.WriteString(call.stderr, testDryRunLogs)
+				return err
+			}
+			return nil
+		})
+		args := Args{
+			URL:                  "https://artifactory.example.com",
+			APIKey:               "key",
+			Source:               "dist/app.zip",
+			Target:               "repo/app/",
+			SyncDeletes:          "repo/app/",
+			SyncDeletesThreshold: test.threshold,
+			Quiet:                "true",
+		}
+		err := Exec(context.Background(), args)
+		if test.err {
+			if err == nil {
+				t.Errorf("Expect error when the deletions exceed a threshold of %d", test.threshold)
+			} else if !strings.Contains(err.Error(), "would delete 3 artifacts") {
+				t.Errorf("Want error reporting the deletions, got %q", err)
+			}
+		} else if err != nil {
+			t.Errorf("Unexpected error for a threshold of %d: %s", test.threshold, err)
+		}
+		if len(runner.calls) != test.calls {
+			t.Errorf("Want %d commands for a threshold of %d, got %d", test.calls, test.threshold, len(runner.calls))
+			continue
+		}
+		if !contains(runner.calls[0].args, "--dry-run") {
+			t.Errorf("Want the dry run first, got %q", runner.calls[0].args)
+		}
+		if test.calls > 1 && contains(runner.calls[1].args, "--dry-run") {
+			t.Errorf("Want the upload after the dry run, got %q", runner.calls[1].args)
+		}
+	}
+}
+
+func TestExecSyncDeletesThresholdNotConfirmed(t *testing.T) {
+	runner := useFakeRunner(t, nil)
+	args := Args{
+		URL:                  "https://artifactory.example.com",
+		APIKey:               "key",
+		Source:               "dist/app.zip",
+		Target:               "repo/app/",
+		SyncDeletes:          "repo/app/",
+		SyncDeletesThreshold: 1,
+	}
+	if err := Exec(context.Background(), args); err != nil {
+		t.Fatal(err)
+	}
+	if len(runner.calls) != 1 {
+		t.Errorf("Want no separate dry run when the upload is a dry run, got %d commands", len(runner.calls))
+	}
+}
+
+func TestExecSyncDeletesThresholdLogLevel(t *testing.T) {
+	for _, level := range []string{"", "warn", "error", "debug"} {
+		runner := useFakeRunner(t, func(call fakeCall) error {
+			if contains(call.args, "--dry-run") {
+				_, err := io.WriteString(call.stdout, testDryRunSummary)
+				return err
+			}
+			return nil
+		})
+		args := Args{
+			URL:                  "https://artifactory.example.com",
+			APIKey:               "key",
+			Source:               "dist/app.zip",
+			Target:               "repo/app/",
+			SyncDeletes:          "repo/app/",
+			SyncDeletesThreshold: 1,
+			Quiet:                "true",
+			Level:                level,
+		}
+		if err := Exec(context.Background(), args); err != nil {
+			t.Errorf("Unexpected error for log level %q: %s", level, err)
+			continue
+		}
+		want := "JFROG_CLI_LOG_LEVEL=INFO"
+		if level == "debug" {
+			want = "JFROG_CLI_LOG_LEVEL=DEBUG"
+		}
+		if !contains(runner.calls[0].env, want) {
+			t.Errorf("Want %s for the dry run with log level %q, got %q", want, level, runner.calls[0].env)
+		}
+	}
+}
+
+func TestExecSyncDeletesThresholdNoSummary(t *testing.T) {
+	runner := useFakeRunner(t, nil)
+	args := Args{
+		URL:                  "https://artifactory.example.com",
+		APIKey:               "key",
+		Source:               "dist/app.zip",
+		Target:               "repo/app/",
+		SyncDeletes:          "repo/app/",
+		SyncDeletesThreshold: 1,
+		Quiet:                "true",
+	}
+	if err := Exec(context.Background(), args); err == nil {
+		t.Error("Expect error when the dry run prints no summary")
+	}
+	if len(runner.calls) != 1 {
+		t.Errorf("Want no upload after a dry run without summary, got %d commands", len(runner.calls))
+	}
+}
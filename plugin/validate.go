@@ -0,0 +1,136 @@
+// Copyright 2020 the Drone Authors. All rights reserved.
+// Use of this source code is governed by the Blue Oak Model License
+// that can be found in the LICENSE file.
+
+package plugin
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// maxThreads is the largest supported number of threads. When the
+// threads are not set the jfrog cli default of 3 applies.
+const maxThreads = 64
+
+// validate checks the settings up front and returns a single error
+// listing every problem found, so that they can be fixed at once.
+func validate(args Args) error {
+	var problems []string
+	if args.URL == "" {
+		problems = append(problems, "url needs to be set")
+	} else if err := checkURL("url", args.URL); err != nil {
+		problems = append(problems, err.Error())
+	}
+	if needsServer(args) && len(credentials(args)) == 0 {
+		problems = append(problems, "either username/password, api key, access token or identity token needs to be set")
+	}
+
+	switch args.Command {
+	case "", commandUpload:
+		switch {
+		case args.Spec != "" && (args.Source != "" || args.SourceManifest != "" || args.PropsMap != "" || args.Target != ""):
+			problems = append(problems, "either spec or source and target needs to be set, not both")
+		case args.Source != "" && args.SourceManifest != "":
+			problems = append(problems, "either source or source manifest needs to be set, not both")
+		case args.PropsMap != "" && (args.Source != "" || args.SourceManifest != ""):
+			problems = append(problems, "either props map or source needs to be set, not both")
+		case args.Spec == "" && args.Source == "" && args.SourceManifest == "" && args.PropsMap == "":
+			problems = append(problems, "source file needs to be set")
+		case args.Spec == "" && args.Target == "":
+			problems = append(problems, "target path needs to be set")
+		}
+		if args.Spec != "" && parseBoolOrDefault(false, args.AbortOnExisting) {
+			problems = append(problems, "abort on existing cannot be set with spec")
+		}
+	case commandDownload:
+		switch {
+		case args.Spec != "" && args.Source != "":
+			problems = append(problems, "either spec or source needs to be set, not both")
+		case args.Spec == "" && args.Source == "":
+			problems = append(problems, "source path needs to be set")
+		}
+	case commandAQL:
+		if args.AQLQuery != "" && args.AQLFile != "" {
+			problems = append(problems, "either aql or aql file needs to be set, not both")
+		}
+	}
+
+	if args.GPGPassphrase != "" && args.PassphraseFile != "" {
+		problems = append(problems, "either gpg passphrase or passphrase file needs to be set, not both")
+	}
+	if args.DistRules != "" && (args.Site != "" || args.City != "" || args.CountryCodes != "") {
+		problems = append(problems, "either dist rules or site, city and country codes needs to be set, not both")
+	}
+	switch args.OutputFormat {
+	case "", formatText, formatJSON:
+	default:
+		problems = append(problems, fmt.Sprintf("unsupported output format %q, must be text or json", args.OutputFormat))
+	}
+	if args.Target == "" && args.Path != "" && strings.Trim(args.RepoKey, "/") == "" {
+		problems = append(problems, "repo needs to be set with path")
+	}
+	if args.PlatformURL != "" {
+		if err := checkURL("platform url", args.PlatformURL); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	if args.BuildURL != "" {
+		if err := checkURL("build url", args.BuildURL); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	if args.Threads != 0 && (args.Threads < 1 || args.Threads > maxThreads) {
+		problems = append(problems, fmt.Sprintf("threads %d out of range, must be between 1 and %d", args.Threads, maxThreads))
+	}
+	if args.RetryWaitTime < 0 {
+		problems = append(problems, "retry wait time must not be negative")
+	}
+	if args.SyncDeletesThreshold < 0 {
+		problems = append(problems, "sync deletes threshold must not be negative")
+	}
+	if args.WorkingDir != "" {
+		if info, err := os.Stat(args.WorkingDir); err != nil || !info.IsDir() {
+			problems = append(problems, fmt.Sprintf("working dir %q does not exist", args.WorkingDir))
+		}
+	}
+
+	switch len(problems) {
+	case 0:
+		return nil
+	case 1:
+		return errors.New(problems[0])
+	default:
+		return fmt.Errorf("invalid settings: %s", strings.Join(problems, "; "))
+	}
+}
+
+// checkURL returns an error if the named setting is not an http or
+// https url with a host.
+func checkURL(name, s string) error {
+	u, err := url.Parse(s)
+	if err != nil {
+		return fmt.Errorf("invalid %s %q", name, s)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("invalid %s %q, must start with http:// or https://", name, s)
+	}
+	if u.Host == "" {
+		return fmt.Errorf("invalid %s %q, missing host", name, s)
+	}
+	return nil
+}
+
+// needsServer returns true if the command connects to the server,
+// and needs credentials.
+func needsServer(args Args) bool {
+	switch args.Command {
+	case commandCollectEnv, commandCollectGit, commandCollectDeps, commandBuildAppend:
+		return false
+	default:
+		return true
+	}
+}
@@ -0,0 +1,183 @@
+// Copyright 2020 the Drone Authors. All rights reserved.
+// Use of this source code is governed by the Blue Oak Model License
+// that can be found in the LICENSE file.
+
+package plugin
+
+import (
+	"testing"
+)
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		args Args
+		want string
+	}{
+		{
+			name: "upload",
+			args: Args{URL: "https://artifactory.example.com", APIKey: "key", Source: "dist/app.zip", Target: "repo/app/"},
+		},
+		{
+			name: "upload spec",
+			args: Args{URL: "https://artifactory.example.com", APIKey: "key", Spec: "testdata/spec.json"},
+		},
+		{
+			name: "upload source manifest",
+			args: Args{URL: "https://artifactory.example.com", APIKey: "key", SourceManifest: "manifest.txt", Target: "repo/app/"},
+		},
+		{
+			name: "collect env without credentials",
+			args: Args{Command: "collect-env", URL: "https://artifactory.example.com", BuildName: "app"},
+		},
+		{
+			name: "missing url",
+			args: Args{APIKey: "key", Source: "dist/app.zip", Target: "repo/app/"},
+			want: "url needs to be set",
+		},
+		{
+			name: "url with trailing slash",
+			args: Args{URL: "https://artifactory.example.com/artifactory/", APIKey: "key", Source: "dist/app.zip", Target: "repo/app/"},
+		},
+		{
+			name: "url without scheme",
+			args: Args{URL: "artifactory.example.com", APIKey: "key", Source: "dist/app.zip", Target: "repo/app/"},
+			want: `invalid url "artifactory.example.com", must start with http:// or https://`,
+		},
+		{
+			name: "url with unsupported scheme",
+			args: Args{URL: "ftp://artifactory.example.com", APIKey: "key", Source: "dist/app.zip", Target: "repo/app/"},
+			want: `invalid url "ftp://artifactory.example.com", must start with http:// or https://`,
+		},
+		{
+			name: "url without host",
+			args: Args{URL: "https:///artifactory", APIKey: "key", Source: "dist/app.zip", Target: "repo/app/"},
+			want: `invalid url "https:///artifactory", missing host`,
+		},
+		{
+			name: "garbage url",
+			args: Args{URL: "http://[::1", APIKey: "key", Source: "dist/app.zip", Target: "repo/app/"},
+			want: `invalid url "http://[::1"`,
+		},
+		{
+			name: "build url",
+			args: Args{Command: "publish-build", URL: "https://artifactory.example.com", APIKey: "key", BuildName: "app", BuildNumber: "42", BuildURL: "drone.example.com/builds/42"},
+			want: `invalid build url "drone.example.com/builds/42", must start with http:// or https://`,
+		},
+		{
+			name: "missing credentials",
+			args: Args{URL: "https://artifactory.example.com", Source: "dist/app.zip", Target: "repo/app/"},
+			want: "either username/password, api key, access token or identity token needs to be set",
+		},
+		{
+			name: "missing source",
+			args: Args{URL: "https://artifactory.example.com", APIKey: "key", Target: "repo/app/"},
+			want: "source file needs to be set",
+		},
+		{
+			name: "missing target",
+			args: Args{URL: "https://artifactory.example.com", APIKey: "key", Source: "dist/app.zip"},
+			want: "target path needs to be set",
+		},
+		{
+			name: "spec and source",
+			args: Args{URL: "https://artifactory.example.com", APIKey: "key", Spec: "testdata/spec.json", Source: "dist/app.zip"},
+			want: "either spec or source and target needs to be set, not both",
+		},
+		{
+			name: "source and source manifest",
+			args: Args{URL: "https://artifactory.example.com", APIKey: "key", Source: "dist/app.zip", SourceManifest: "manifest.txt", Target: "repo/app/"},
+			want: "either source or source manifest needs to be set, not both",
+		},
+		{
+			name: "props map and source",
+			args: Args{URL: "https://artifactory.example.com", APIKey: "key", Source: "dist/app.zip", PropsMap: `{"dist/*": "type=zip"}`, Target: "repo/app/"},
+			want: "either props map or source needs to be set, not both",
+		},
+		{
+			name: "abort on existing with spec",
+			args: Args{URL: "https://artifactory.example.com", APIKey: "key", Spec: "testdata/spec.json", AbortOnExisting: "true"},
+			want: "abort on existing cannot be set with spec",
+		},
+		{
+			name: "download spec and source",
+			args: Args{Command: "download", URL: "https://artifactory.example.com", APIKey: "key", Spec: "testdata/spec.json", Source: "repo/app/"},
+			want: "either spec or source needs to be set, not both",
+		},
+		{
+			name: "download missing source",
+			args: Args{Command: "download", URL: "https://artifactory.example.com", APIKey: "key"},
+			want: "source path needs to be set",
+		},
+		{
+			name: "aql and aql file",
+			args: Args{Command: "aql", URL: "https://artifactory.example.com", APIKey: "key", AQLQuery: "items.find()", AQLFile: "query.aql"},
+			want: "either aql or aql file needs to be set, not both",
+		},
+		{
+			name: "passphrase and passphrase file",
+			args: Args{Command: "release-bundle-sign", URL: "https://distribution.example.com", APIKey: "key", GPGPassphrase: "secret", PassphraseFile: "passphrase"},
+			want: "either gpg passphrase or passphrase file needs to be set, not both",
+		},
+		{
+			name: "dist rules and site",
+			args: Args{Command: "release-bundle-distribute", URL: "https://artifactory.example.com", APIKey: "key", DistRules: "rules.json", Site: "edge-*"},
+			want: "either dist rules or site, city and country codes needs to be set, not both",
+		},
+		{
+			name: "output format",
+			args: Args{URL: "https://artifactory.example.com", APIKey: "key", Source: "dist/app.zip", Target: "repo/app/", OutputFormat: "yaml"},
+			want: `unsupported output format "yaml", must be text or json`,
+		},
+		{
+			name: "threads",
+			args: Args{URL: "https://artifactory.example.com", APIKey: "key", Source: "dist/app.zip", Target: "repo/app/", Threads: 64},
+		},
+		{
+			name: "too many threads",
+			args: Args{URL: "https://artifactory.example.com", APIKey: "key", Source: "dist/app.zip", Target: "repo/app/", Threads: 65},
+			want: "threads 65 out of range, must be between 1 and 64",
+		},
+		{
+			name: "negative threads",
+			args: Args{URL: "https://artifactory.example.com", APIKey: "key", Source: "dist/app.zip", Target: "repo/app/", Threads: -1},
+			want: "threads -1 out of range, must be between 1 and 64",
+		},
+		{
+			name: "negative retry wait time",
+			args: Args{URL: "https://artifactory.example.com", APIKey: "key", Source: "dist/app.zip", Target: "repo/app/", RetryWaitTime: -1},
+			want: "retry wait time must not be negative",
+		},
+		{
+			name: "negative sync deletes threshold",
+			args: Args{URL: "https://artifactory.example.com", APIKey: "key", Source: "dist/app.zip", Target: "repo/app/", SyncDeletes: "repo/app/", SyncDeletesThreshold: -1},
+			want: "sync deletes threshold must not be negative",
+		},
+		{
+			name: "working dir",
+			args: Args{URL: "https://artifactory.example.com", APIKey: "key", Source: "dist/app.zip", Target: "repo/app/", WorkingDir: "testdata/missing"},
+			want: `working dir "testdata/missing" does not exist`,
+		},
+		{
+			name: "multiple problems",
+			args: Args{Source: "dist/app.zip"},
+			want: "invalid settings: url needs to be set; either username/password, api key, access token or identity token needs to be set; target path needs to be set",
+		},
+	}
+	for _, test := range tests {
+		err := validate(test.args)
+		if test.want == "" {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %s", test.name, err)
+			}
+			continue
+		}
+		if err == nil {
+			t.Errorf("%s: expect error %q", test.name, test.want)
+			continue
+		}
+		if got := err.Error(); got != test.want {
+			t.Errorf("%s: want error\n%s\ngot\n%s", test.name, test.want, got)
+		}
+	}
+}